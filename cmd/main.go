@@ -9,6 +9,7 @@ import (
 	"e-commerce-users/internal/app"
 	"e-commerce-users/internal/config"
 	"e-commerce-users/pkg/logger"
+	"e-commerce-users/pkg/logger/sl"
 )
 
 func main() {
@@ -16,6 +17,16 @@ func main() {
 
 	log := logger.New(cfg.ENV)
 
+	// Admin commands share config with the service and exit without starting it
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := app.RunKeys(cfg, os.Args[2:], os.Stdout); err != nil {
+			log.Error("keys command failed", sl.Err(err))
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	log.Info("starting server", slog.String("port", cfg.HTTPServer.Port))
 
 	app := app.New(cfg, log)
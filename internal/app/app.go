@@ -1,15 +1,28 @@
 package app
 
 import (
+	"expvar"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	apphttp "e-commerce-users/internal/app/http"
 	"e-commerce-users/internal/config"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/lib/oidc"
+	"e-commerce-users/internal/lib/passkey"
+	"e-commerce-users/internal/lib/password"
+	"e-commerce-users/internal/lib/pwned"
 	cache_repo "e-commerce-users/internal/repositories/cache"
+	client_repo "e-commerce-users/internal/repositories/client"
 	"e-commerce-users/internal/repositories/mailer"
+	mfa_repo "e-commerce-users/internal/repositories/mfa"
+	passkey_repo "e-commerce-users/internal/repositories/passkey"
+	session_repo "e-commerce-users/internal/repositories/session"
 	user_repo "e-commerce-users/internal/repositories/user"
 	auth_service "e-commerce-users/internal/services/auth"
+	oauth_service "e-commerce-users/internal/services/oauth"
 	users_service "e-commerce-users/internal/services/users"
 	"e-commerce-users/pkg/logger/sl"
 	"e-commerce-users/pkg/postgres"
@@ -57,22 +70,107 @@ func (a *App) Start() {
 
 	// Repositories
 	userRepo := user_repo.New(a.strg)
+	sessionRepo := session_repo.New(a.strg)
+	mfaRepo := mfa_repo.New(a.strg)
+	passkeyRepo := passkey_repo.New(a.strg)
+	clientRepo := client_repo.New(a.strg)
 	cache := cache_repo.New(a.cache, a.cfg.Prefix)
 	mailer := mailer.New(&a.cfg.SMTP)
 
+	signingKeys, err := jwt_lib.LoadKeySet(&a.cfg.Tokens)
+	if err != nil {
+		log.Error("failed to load token signing keys", sl.Err(err))
+		os.Exit(1)
+	}
+
+	go a.reloadKeysOnSignal(signingKeys)
+
+	webAuthn, err := passkey.New(&a.cfg.WebAuthn)
+	if err != nil {
+		log.Error("failed to init webauthn relying party", sl.Err(err))
+		os.Exit(1)
+	}
+
+	hasher, err := password.New(&a.cfg.Password)
+	if err != nil {
+		log.Error("failed to init password hasher", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// Hashing runs on bounded workers, so sign in bursts can't take every CPU from other requests
+	hashPool := password.NewPool(hasher, &a.cfg.Password)
+	expvar.Publish("password_pool", hashPool.Metrics())
+
+	policy := password.NewPolicy(&a.cfg.Password)
+
+	breaches, err := pwned.New(&a.cfg.Password)
+	if err != nil {
+		log.Error("failed to open breached passwords corpus", sl.Err(err))
+		os.Exit(1)
+	}
+
+	providers, err := oidc.New(&a.cfg.Federation)
+	if err != nil {
+		log.Error("failed to load federation providers", sl.Err(err))
+		os.Exit(1)
+	}
+
 	// Services
 	authSrvc := auth_service.New(
 		&auth_service.Config{
-			Repo:    userRepo,
-			Cache:   cache,
-			Mailer:  mailer,
-			TknsCfg: &a.cfg.Tokens,
+			Repo:            userRepo,
+			SessionRepo:     sessionRepo,
+			MFARepo:         mfaRepo,
+			PasskeyRepo:     passkeyRepo,
+			Cache:           cache,
+			Mailer:          mailer,
+			Hasher:          hashPool,
+			Policy:          policy,
+			Breaches:        breaches,
+			RejectBreached:  a.cfg.Password.BreachMode == pwned.ModeReject,
+			TknsCfg:         &a.cfg.Tokens,
+			SigningKeys:     signingKeys,
+			MFACfg:          &a.cfg.MFA,
+			LockoutCfg:      &a.cfg.Lockout,
+			ConfirmCfg:      &a.cfg.Confirmation,
+			WebAuthn:        webAuthn,
+			Providers:       providers,
+			FedCfg:          &a.cfg.Federation,
+			AntiEnumeration: a.cfg.AntiEnumeration,
 		},
 	)
 
 	usersSrvc := users_service.New(
 		&users_service.Config{
-			Repo: userRepo,
+			Repo:               userRepo,
+			SessionRepo:        sessionRepo,
+			MFARepo:            mfaRepo,
+			PasskeyRepo:        passkeyRepo,
+			Cache:              cache,
+			Hasher:             hashPool,
+			Lockout:            authSrvc,
+			Policy:             policy,
+			Breaches:           breaches,
+			RejectBreached:     a.cfg.Password.BreachMode == pwned.ModeReject,
+			WebAuthn:           webAuthn,
+			VersionTTL:         a.cfg.Tokens.VersionCacheTTL,
+			MFAIssuer:          a.cfg.MFA.Issuer,
+			RecoveryCodes:      a.cfg.MFA.RecoveryCodes,
+			Providers:          providers,
+			FederationStateTTL: a.cfg.Federation.StateTTL,
+			FreshSignInTTL:     a.cfg.Tokens.FreshSignInTTL,
+		},
+	)
+
+	oauthSrvc := oauth_service.New(
+		&oauth_service.Config{
+			Repo:          userRepo,
+			ClientRepo:    clientRepo,
+			Cache:         cache,
+			SessionIssuer: authSrvc,
+			SigningKeys:   signingKeys,
+			TknsCfg:       &a.cfg.Tokens,
+			OAuthCfg:      &a.cfg.OAuth,
 		},
 	)
 
@@ -80,6 +178,9 @@ func (a *App) Start() {
 	httpServer := apphttp.New(
 		authSrvc,
 		usersSrvc,
+		oauthSrvc,
+		signingKeys,
+		cache,
 		log,
 		a.cfg,
 	)
@@ -113,6 +214,25 @@ func (a *App) Stop() {
 	}
 }
 
+// reloadKeysOnSignal reloads signing keys on SIGHUP, so keys are rotated without restart
+func (a *App) reloadKeysOnSignal(keys *jwt_lib.KeySet) {
+	const op = "app.reloadKeysOnSignal"
+
+	log := a.log.With(slog.String("op", op))
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	for range reload {
+		if err := keys.Reload(&a.cfg.Tokens); err != nil {
+			log.Error("failed to reload signing keys, keeping previous ones", sl.Err(err))
+			continue
+		}
+
+		log.Info("signing keys reloaded", slog.String("active_kid", keys.Active().ID))
+	}
+}
+
 // initStorage initializes connection with storage
 func (a *App) initStorage() error {
 	storage, err := postgres.NewPool(&a.cfg.Postgres)
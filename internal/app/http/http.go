@@ -2,16 +2,24 @@ package apphttp
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"e-commerce-users/internal/config"
 	auth_http "e-commerce-users/internal/delivery/http/auth"
+	clients_http "e-commerce-users/internal/delivery/http/clients"
+	keys_http "e-commerce-users/internal/delivery/http/keys"
+	lockouts_http "e-commerce-users/internal/delivery/http/lockouts"
+	oauth_http "e-commerce-users/internal/delivery/http/oauth"
 	users_http "e-commerce-users/internal/delivery/http/users"
 	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
 	auth_service "e-commerce-users/internal/services/auth"
+	oauth_service "e-commerce-users/internal/services/oauth"
 	users_service "e-commerce-users/internal/services/users"
 
 	"github.com/go-chi/chi/middleware"
@@ -20,18 +28,27 @@ import (
 
 type App struct {
 	server *http.Server
-	log    *slog.Logger
+	// debug serves metrics on internal address, nil if it is not configured
+	debug *http.Server
+	log   *slog.Logger
 }
 
 func New(
 	authSrvc *auth_service.Service,
 	usrSrvc *users_service.Service,
+	oauthSrvc *oauth_service.Service,
+	signingKeys *jwt_lib.KeySet,
+	limiter http_lib.RateLimiter,
 	log *slog.Logger,
 	cfg *config.Config,
 ) *App {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
+	if cfg.HTTPServer.TrustProxy {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(http_lib.Client)
 	r.Use(http_lib.TraceID)
 	r.Use(http_lib.Logging(log))
 	r.Use(middleware.Recoverer)
@@ -40,22 +57,87 @@ func New(
 		w.WriteHeader(http.StatusOK)
 	})
 
+	keysCtrl := keys_http.New(
+		&keys_http.Config{
+			SigningKeys: signingKeys,
+			OAuthCfg:    &cfg.OAuth,
+			Scopes:      oauth_service.SupportedScopes,
+		},
+	)
+	r.Mount("/.well-known", keysCtrl.Register())
+
+	oauthLimit := http_lib.RateLimit(limiter, http_lib.RateLimitPolicy{
+		Name:   "oauth",
+		Limit:  cfg.RateLimit.OAuth,
+		Window: cfg.RateLimit.Window,
+		Key:    http_lib.KeyByIP,
+	})
+	authLimit := http_lib.RateLimit(limiter, http_lib.RateLimitPolicy{
+		Name:   "auth",
+		Limit:  cfg.RateLimit.Auth,
+		Window: cfg.RateLimit.Window,
+		Key: func(r *http.Request) string {
+			// Forward auth is called by gateway on every request, limiting it by gateway IP would limit everyone
+			if strings.HasSuffix(r.URL.Path, "/auth/verify") {
+				return ""
+			}
+			return http_lib.KeyByIP(r)
+		},
+	})
+	usersLimit := http_lib.RateLimit(limiter, http_lib.RateLimitPolicy{
+		Name:   "users",
+		Limit:  cfg.RateLimit.Users,
+		Window: cfg.RateLimit.Window,
+		Key:    http_lib.KeyBySubject,
+	})
+
+	oauthCtrl := oauth_http.New(
+		&oauth_http.Config{
+			OAuthService: oauthSrvc,
+			OAuthCfg:     &cfg.OAuth,
+		},
+	)
+	r.With(oauthLimit).Mount("/oauth", oauthCtrl.Register())
+	r.With(oauthLimit).Mount("/userinfo", oauthCtrl.RegisterUserInfo())
+
 	r.Route("/api/v1", func(r chi.Router) {
 		authCtrl := auth_http.New(
 			&auth_http.Config{
-				AuthService: authSrvc,
-				TknsCfg:     &cfg.Tokens,
+				AuthService:      authSrvc,
+				VersionValidator: usrSrvc,
+				TknsCfg:          &cfg.Tokens,
+				SigningKeys:      signingKeys,
 			},
 		)
-		r.Mount("/auth", authCtrl.Register())
+		r.With(authLimit).Mount("/auth", authCtrl.Register())
 
 		usersCtrl := users_http.New(
 			&users_http.Config{
-				UsrSrvc: usrSrvc,
-				TknsCfg: cfg.Tokens,
+				UsrSrvc:     usrSrvc,
+				TknsCfg:     cfg.Tokens,
+				SigningKeys: signingKeys,
+			},
+		)
+		// Token is verified before the limit, so users are limited by subject
+		r.With(signingKeys.Verifier(), usersLimit).Mount("/users", usersCtrl.Register())
+
+		clientsCtrl := clients_http.New(
+			&clients_http.Config{
+				ClientsService:   oauthSrvc,
+				VersionValidator: usrSrvc,
+				SigningKeys:      signingKeys,
+			},
+		)
+		r.Mount("/admin/clients", clientsCtrl.Register())
+
+		lockoutsCtrl := lockouts_http.New(
+			&lockouts_http.Config{
+				LockoutsService:  authSrvc,
+				VersionValidator: usrSrvc,
+				SigningKeys:      signingKeys,
 			},
 		)
-		r.Mount("/users", usersCtrl.Register())
+		r.Mount("/admin/lockouts", lockoutsCtrl.Register())
 	})
 
 	srv := &http.Server{
@@ -65,10 +147,25 @@ func New(
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	return &App{
+	app := &App{
 		server: srv,
 		log:    log,
 	}
+
+	// Runtime and password hashing pool metrics are kept off the public listener
+	if cfg.HTTPServer.DebugAddress != "" {
+		debug := http.NewServeMux()
+		debug.Handle("GET /debug/vars", expvar.Handler())
+
+		app.debug = &http.Server{
+			Addr:              cfg.HTTPServer.DebugAddress,
+			Handler:           debug,
+			IdleTimeout:       cfg.HTTPServer.IdleTimeout,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+
+	return app
 }
 
 func (a *App) Start() error {
@@ -78,6 +175,14 @@ func (a *App) Start() error {
 		}
 	}()
 
+	if a.debug != nil {
+		go func() {
+			if err := a.debug.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				a.log.Error("debug HTTP server error", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -91,5 +196,11 @@ func (a *App) Stop() error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if a.debug != nil {
+		if err := a.debug.Shutdown(ctx); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"e-commerce-users/internal/config"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+)
+
+var ErrUsage = errors.New("usage: keys rotate [-alg HS256|RS256|EdDSA] | keys prune [-retention duration] | keys list")
+
+// RunKeys manages signing keyring. Running service picks changes up on SIGHUP
+func RunKeys(cfg *config.Config, args []string, out io.Writer) error {
+	const op = "app.RunKeys"
+
+	if cfg.Tokens.KeysDir == "" {
+		return fmt.Errorf("%s: TOKENS_KEYS_DIR is not set", op)
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUsage)
+	}
+
+	kr := jwt_lib.NewKeyring(cfg.Tokens.KeysDir)
+
+	switch args[0] {
+	case "rotate":
+		fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+		alg := fs.String("alg", "EdDSA", "algorithm of the new key")
+		if err := fs.Parse(args[1:]); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		key, err := kr.Rotate(*alg, time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		fmt.Fprintf(out, "new active key %s (%s), send SIGHUP to the service to apply\n", key.ID, key.Alg()) //nolint:errcheck
+	case "prune":
+		fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+		// Refresh token is the longest-living token signed by the key
+		retention := fs.Duration("retention", cfg.Tokens.RefreshTTL, "how long retired keys are kept")
+		if err := fs.Parse(args[1:]); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		pruned, err := kr.Prune(*retention, time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, id := range pruned {
+			fmt.Fprintf(out, "removed retired key %s\n", id) //nolint:errcheck
+		}
+	case "list":
+		active, retired, err := kr.Load()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		fmt.Fprintf(out, "%s\t%s\tactive\n", active.ID, active.Alg()) //nolint:errcheck
+		for _, k := range retired {
+			fmt.Fprintf(out, "%s\t%s\tretired\n", k.ID, k.Alg()) //nolint:errcheck
+		}
+	default:
+		return fmt.Errorf("%s: %w", op, ErrUsage)
+	}
+
+	return nil
+}
@@ -9,19 +9,34 @@ import (
 )
 
 type Config struct {
-	ENV        string     `env:"ENV" env-default:"dev"`
-	Prefix     string     `env:"PREFIX" env-default:""`
-	HTTPServer HTTPServer `env-required:"true"`
-	Postgres   Postgres   `env-required:"true"`
-	Redis      Redis      `env-required:"true"`
-	SMTP       SMTP       `env-required:"true"`
-	Tokens     Tokens     `env-required:"true"`
+	ENV          string     `env:"ENV" env-default:"dev"`
+	Prefix       string     `env:"PREFIX" env-default:""`
+	HTTPServer   HTTPServer `env-required:"true"`
+	Postgres     Postgres   `env-required:"true"`
+	Redis        Redis      `env-required:"true"`
+	SMTP         SMTP       `env-required:"true"`
+	Tokens       Tokens     `env-required:"true"`
+	Password     Password
+	MFA          MFA
+	Lockout      Lockout
+	Confirmation Confirmation
+	WebAuthn     WebAuthn
+	OAuth        OAuth
+	Federation   Federation
+	RateLimit    RateLimit
+	// AntiEnumeration makes sign up, sign in and resend answer the same whether account exists or not
+	AntiEnumeration bool `env:"ANTI_ENUMERATION" env-default:"true"`
 }
 
 type HTTPServer struct {
 	Host        string        `env:"HTTP_SERVER_HOST" env-required:"true"`
 	Port        string        `env:"HTTP_SERVER_PORT" env-required:"true"`
 	IdleTimeout time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" env-required:"true"`
+	// TrustProxy enables reading client IP from X-Forwarded-For / X-Real-IP headers
+	TrustProxy bool `env:"HTTP_SERVER_TRUST_PROXY" env-default:"false"`
+	// DebugAddress is internal listener address serving runtime and hashing pool metrics.
+	// Metrics are not served if it is empty
+	DebugAddress string `env:"HTTP_SERVER_DEBUG_ADDRESS" env-default:""`
 }
 
 type Postgres struct {
@@ -45,12 +60,129 @@ type SMTP struct {
 	Host     string        `env:"SMTP_HOST" env-required:"true"`
 	Port     string        `env:"SMTP_PORT" env-required:"true"`
 	CodeTTL  time.Duration `env:"SMTP_CODE_TTL" env-required:"true"`
+	ResetTTL time.Duration `env:"SMTP_RESET_TTL" env-default:"15m"`
+	// MagicLinkURL is frontend page consuming sign in link, token is added as query parameter.
+	// Bare token is sent if it is empty
+	MagicLinkURL string        `env:"SMTP_MAGIC_LINK_URL" env-default:""`
+	MagicLinkTTL time.Duration `env:"SMTP_MAGIC_LINK_TTL" env-default:"15m"`
 }
 
 type Tokens struct {
-	Secret     string        `env:"TOKENS_SECRET" env-required:"true"`
+	// Secret is HS256 signing key. Required unless PrivateKeyFile or KeysDir is set
+	Secret string `env:"TOKENS_SECRET" env-default:""`
+	// PrivateKeyFile is path to PEM encoded RSA or Ed25519 private key. Enables RS256/EdDSA signing
+	PrivateKeyFile string `env:"TOKENS_PRIVATE_KEY_FILE" env-default:""`
+	// KeyID is kid header of issued tokens. Defaults to public key thumbprint for asymmetric keys
+	KeyID string `env:"TOKENS_KEY_ID" env-default:""`
+	// KeysDir is keyring directory with active and retired keys, which enables rotation without restart.
+	// Takes precedence over Secret and PrivateKeyFile
+	KeysDir    string        `env:"TOKENS_KEYS_DIR" env-default:""`
 	AccessTTL  time.Duration `env:"TOKENS_ACCESS_TTL" env-required:"true"`
 	RefreshTTL time.Duration `env:"TOKENS_REFRESH_TTL" env-required:"true"`
+	// VerifyVersion enables comparing token version with the actual user version on every request
+	VerifyVersion   bool          `env:"TOKENS_VERIFY_VERSION" env-default:"false"`
+	VersionCacheTTL time.Duration `env:"TOKENS_VERSION_CACHE_TTL" env-default:"30s"`
+	// FreshSignInTTL is time after sign in, within which user without password may set one
+	FreshSignInTTL time.Duration `env:"TOKENS_FRESH_SIGN_IN_TTL" env-default:"5m"`
+}
+
+type Password struct {
+	// Algorithm hashes new passwords, argon2id or bcrypt. Hashes of the other one are still
+	// verified and replaced on sign in, as well as hashes with outdated parameters
+	Algorithm string `env:"PASSWORD_ALGORITHM" env-default:"argon2id"`
+	// Argon2Memory is memory used by argon2id in KiB
+	Argon2Memory      uint32 `env:"PASSWORD_ARGON2_MEMORY" env-default:"65536"`
+	Argon2Time        uint32 `env:"PASSWORD_ARGON2_TIME" env-default:"3"`
+	Argon2Parallelism uint8  `env:"PASSWORD_ARGON2_PARALLELISM" env-default:"2"`
+	BcryptCost        int    `env:"PASSWORD_BCRYPT_COST" env-default:"10"`
+	// MinLength is minimal number of characters in password
+	MinLength int `env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	// MaxLength is maximal number of bytes in password. It bounds hashing cost and must not exceed 72 with bcrypt
+	MaxLength int `env:"PASSWORD_MAX_LENGTH" env-default:"72"`
+	// MinClasses is minimal number of character classes: lowercase, uppercase, digits and others
+	MinClasses int `env:"PASSWORD_MIN_CLASSES" env-default:"3"`
+	// BreachCorpus is Have I Been Pwned SHA-1 corpus: directory of range files named by hash prefix
+	// or single file ordered by hash. Breached passwords are not checked without it
+	BreachCorpus string `env:"PASSWORD_BREACH_CORPUS" env-default:""`
+	// BreachMode is reject to refuse breached passwords or warn to accept them with a warning in logs
+	BreachMode string `env:"PASSWORD_BREACH_MODE" env-default:"reject"`
+	// Workers is number of hashes computed at once. Defaults to half of CPUs
+	Workers int `env:"PASSWORD_WORKERS" env-default:"0"`
+	// QueueSize is number of requests waiting for a free worker, others get 503 at once
+	QueueSize int `env:"PASSWORD_QUEUE_SIZE" env-default:"64"`
+	// QueueTimeout is the longest wait for a free worker, zero waits without limit
+	QueueTimeout time.Duration `env:"PASSWORD_QUEUE_TIMEOUT" env-default:"2s"`
+}
+
+type MFA struct {
+	Issuer string `env:"MFA_ISSUER" env-default:"e-commerce"`
+	// TokenTTL is lifetime of mfa_pending token issued after the first factor
+	TokenTTL time.Duration `env:"MFA_TOKEN_TTL" env-default:"5m"`
+	// MaxAttempts is number of wrong codes of user within LOCKOUT_WINDOW after which the account is locked
+	MaxAttempts int `env:"MFA_MAX_ATTEMPTS" env-default:"5"`
+	// RecoveryCodes is number of one-time recovery codes generated for user
+	RecoveryCodes int `env:"MFA_RECOVERY_CODES" env-default:"10"`
+}
+
+type Lockout struct {
+	// MaxAttempts is number of wrong passwords within Window after which account is locked
+	MaxAttempts int           `env:"LOCKOUT_MAX_ATTEMPTS" env-default:"5"`
+	Window      time.Duration `env:"LOCKOUT_WINDOW" env-default:"15m"`
+	// Duration is the first lock duration. Every next lock within a day is twice longer, up to MaxDuration
+	Duration    time.Duration `env:"LOCKOUT_DURATION" env-default:"1m"`
+	MaxDuration time.Duration `env:"LOCKOUT_MAX_DURATION" env-default:"1h"`
+	// IPMaxAttempts is number of failed sign ins from single IP within Window, after which
+	// the IP is rejected with 429 whatever account it signs in to
+	IPMaxAttempts int `env:"LOCKOUT_IP_MAX_ATTEMPTS" env-default:"20"`
+	// UnlockTTL is lifetime of unlock token emailed to user when account gets locked
+	UnlockTTL time.Duration `env:"LOCKOUT_UNLOCK_TTL" env-default:"1h"`
+}
+
+type Confirmation struct {
+	// MaxAttempts is number of wrong codes after which confirmation code is invalidated
+	MaxAttempts int `env:"CONFIRMATION_MAX_ATTEMPTS" env-default:"5"`
+	// ResendCooldown is time user waits before the next code can be sent
+	ResendCooldown time.Duration `env:"CONFIRMATION_RESEND_COOLDOWN" env-default:"1m"`
+	// DailyLimit is number of codes sent to a single email within a day
+	DailyLimit int `env:"CONFIRMATION_DAILY_LIMIT" env-default:"10"`
+}
+
+type WebAuthn struct {
+	// RPID is relying party identifier, usually the domain of frontend without scheme and port
+	RPID          string   `env:"WEBAUTHN_RP_ID" env-default:"localhost"`
+	RPDisplayName string   `env:"WEBAUTHN_RP_DISPLAY_NAME" env-default:"e-commerce"`
+	RPOrigins     []string `env:"WEBAUTHN_RP_ORIGINS" env-default:"http://localhost:8080" env-separator:","`
+	// CeremonyTTL is time given to user to answer registration or login challenge
+	CeremonyTTL time.Duration `env:"WEBAUTHN_CEREMONY_TTL" env-default:"5m"`
+}
+
+type OAuth struct {
+	// Issuer is public URL of this service, put to ID tokens and discovery document
+	Issuer string `env:"OAUTH_ISSUER" env-default:"http://localhost:5000"`
+	// LoginURL is frontend sign in page. Unauthenticated users are sent there from
+	// authorization endpoint with return_to parameter pointing back to it
+	LoginURL string `env:"OAUTH_LOGIN_URL" env-default:""`
+	// CodeTTL is lifetime of authorization code
+	CodeTTL time.Duration `env:"OAUTH_CODE_TTL" env-default:"1m"`
+}
+
+type Federation struct {
+	// ProvidersFile is JSON file listing external OpenID Connect providers users can sign in with.
+	// Federated sign in is disabled without it
+	ProvidersFile string `env:"FEDERATION_PROVIDERS_FILE" env-default:""`
+	// StateTTL is time given to user to sign in at provider
+	StateTTL time.Duration `env:"FEDERATION_STATE_TTL" env-default:"10m"`
+}
+
+// RateLimit is number of requests allowed within Window per route group. Zero disables the limit
+type RateLimit struct {
+	Window time.Duration `env:"RATE_LIMIT_WINDOW" env-default:"1m"`
+	// Auth limits every client IP on auth endpoints, except forward auth called by gateway
+	Auth int `env:"RATE_LIMIT_AUTH" env-default:"60"`
+	// Users limits every signed in user on account endpoints
+	Users int `env:"RATE_LIMIT_USERS" env-default:"300"`
+	// OAuth limits every client IP on OAuth and OpenID Connect endpoints
+	OAuth int `env:"RATE_LIMIT_OAUTH" env-default:"300"`
 }
 
 func MustLoad() *Config {
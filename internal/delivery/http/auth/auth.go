@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -9,32 +10,51 @@ import (
 
 	"e-commerce-users/internal/config"
 	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
 	"e-commerce-users/internal/services"
 	"e-commerce-users/pkg/logger/sl"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
+	"github.com/go-webauthn/webauthn/protocol"
 )
 
 type AuthService interface {
 	SignUp(ctx context.Context, name, surname, birthdate, email, password string) error
 	SignIn(ctx context.Context, name, password string) (string, string, error)
+	SignInMFA(ctx context.Context, mfaToken, code, recoveryCode string) (string, string, error)
 	Logout(ctx context.Context, accessToken, refreshToken string) error
 	Confirm(ctx context.Context, email, code string) (string, string, error)
 	Refresh(ctx context.Context, refreshToken string) (string, string, error)
 	ResendCode(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	Unlock(ctx context.Context, token string) error
+	SendMagicLink(ctx context.Context, email string) error
+	ConsumeMagicLink(ctx context.Context, token string) (string, string, error)
+	BeginPasskeyLogin(ctx context.Context) (*protocol.CredentialAssertion, error)
+	FinishPasskeyLogin(ctx context.Context, response []byte) (string, string, error)
+	BeginFederatedSignIn(ctx context.Context, provider string) (string, error)
+	FinishFederatedSignIn(ctx context.Context, provider, code, state string) (string, string, error)
+	IsBlacklisted(ctx context.Context, token string) (bool, error)
 }
 
 type Controller struct {
 	as     AuthService
+	vv     http_lib.VersionValidator
 	tCfg   *config.Tokens
+	keys   *jwt_lib.KeySet
 	valdtr *validator.Validate
 }
 
 type Config struct {
 	AuthService AuthService
-	TknsCfg     *config.Tokens
+	// VersionValidator checks token version on forward auth
+	VersionValidator http_lib.VersionValidator
+	TknsCfg          *config.Tokens
+	SigningKeys      *jwt_lib.KeySet
 }
 
 type signUpCredentials struct {
@@ -50,6 +70,42 @@ type signInCredentials struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type signInMFARequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"excluded_with=Code"`
+}
+
+type passkeyRequest struct {
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type unlockRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type magicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type consumeMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type federatedRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+type redirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type mfaRequiredResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
 type tokensRequest struct {
 	AccessToken  string `json:"access_token" validate:"required"`
 	RefreshToken string `json:"refresh_token" validate:"required"`
@@ -73,10 +129,21 @@ type refreshRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
 func New(cfg *Config) *Controller {
 	return &Controller{
 		as:     cfg.AuthService,
+		vv:     cfg.VersionValidator,
 		tCfg:   cfg.TknsCfg,
+		keys:   cfg.SigningKeys,
 		valdtr: validator.New(),
 	}
 }
@@ -86,10 +153,40 @@ func (c *Controller) Register() *chi.Mux {
 
 	r.Post("/sign-up", c.signUp)
 	r.Post("/sign-in", c.signIn)
+	r.Post("/sign-in/mfa", c.signInMFA)
 	r.Post("/logout", c.logout)
 	r.Post("/confirm", c.confirm)
 	r.Post("/resend", c.resend)
 	r.Post("/refresh", c.refresh)
+	r.Post("/unlock", c.unlock)
+
+	// Reverse proxies may forward the original method of subrequest, so every method is accepted
+	r.With(
+		c.keys.Verifier(),
+		http_lib.Authenticator,
+		http_lib.BlacklistAuthenticator(c.as),
+		http_lib.VersionAuthenticator(c.vv),
+	).HandleFunc("/verify", c.verify)
+
+	r.Route("/password", func(r chi.Router) {
+		r.Post("/forgot", c.forgotPassword)
+		r.Post("/reset", c.resetPassword)
+	})
+
+	r.Route("/passkey", func(r chi.Router) {
+		r.Post("/begin", c.beginPasskeyLogin)
+		r.Post("/finish", c.finishPasskeyLogin)
+	})
+
+	r.Route("/magic-link", func(r chi.Router) {
+		r.Post("/", c.sendMagicLink)
+		r.Post("/consume", c.consumeMagicLink)
+	})
+
+	r.Route("/federated/{provider}", func(r chi.Router) {
+		r.Post("/begin", c.beginFederatedSignIn)
+		r.Post("/finish", c.finishFederatedSignIn)
+	})
 
 	return r
 }
@@ -126,15 +223,25 @@ func (c *Controller) signUp(w http.ResponseWriter, r *http.Request) {
 			http_lib.ErrConflict(w, r, "User already exists")
 			return
 		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			http_lib.ErrInvalid(w, r, http_lib.FieldErrors{"password": policyErr.Rules})
+			return
+		}
 
 		http_lib.ErrInternal(w, r)
 		return
 	}
 
-	log.Info("user signed up succesfully", slog.String("email", creds.Email))
+	log.Info("sign up request accepted", slog.String("email", creds.Email))
 
 	render.Status(r, http.StatusCreated)
-	render.Render(w, r, http_lib.RespOk("Registration successful. A confirmation code has been sent to your email")) //nolint:errcheck
+	// The same answer is given when account exists, its owner gets a notice instead of the code
+	render.Render(w, r, http_lib.RespOk("Check your email to complete registration")) //nolint:errcheck
 }
 
 func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) {
@@ -160,6 +267,27 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) {
 
 	assessToken, refreshToken, err := c.as.SignIn(r.Context(), creds.Email, creds.Password)
 	if err != nil {
+		var mfaErr *services.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			render.Status(r, http.StatusOK)
+			render.Render(w, r, mfaRequiredResponse{ //nolint:errcheck
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+			})
+			return
+		}
+
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+		var rateErr *services.RateLimitedError
+		if errors.As(err, &rateErr) {
+			http_lib.ErrTooManyRequests(w, r, "Too many requests", rateErr.RetryAfter)
+			return
+		}
+
 		if errors.Is(err, services.ErrNotFound) {
 			http_lib.ErrUnauthorized(w, r, "User not found")
 			return
@@ -168,6 +296,10 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) {
 			http_lib.ErrUnauthorized(w, r, "Invalid credentials")
 			return
 		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
 
 		http_lib.ErrInternal(w, r)
 		return
@@ -182,6 +314,67 @@ func (c *Controller) signIn(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (c *Controller) signInMFA(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.signInMFA"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var mfaReq signInMFARequest
+	if err := render.DecodeJSON(r.Body, &mfaReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(mfaReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	accTkn, rfrshTkn, err := c.as.SignInMFA(r.Context(), mfaReq.MFAToken, mfaReq.Code, mfaReq.RecoveryCode)
+	if err != nil {
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+
+		if errors.Is(err, services.ErrCode) || errors.Is(err, services.ErrInvalidCredentials) {
+			http_lib.ErrUnauthorized(w, r, "Invalid code")
+			return
+		}
+		if errors.Is(err, services.ErrTokenBlacklisted) {
+			http_lib.ErrUnauthorized(w, r, "Token revoked")
+			return
+		}
+		if errors.Is(err, services.ErrTokenExpired) {
+			http_lib.ErrUnauthorized(w, r, "Token expired")
+			return
+		}
+		if errors.Is(err, services.ErrTokenInvalid) || errors.Is(err, services.ErrUnexpectedTokenType) {
+			http_lib.ErrUnauthorized(w, r, "Invalid token")
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, tokensResponse{ //nolint:errcheck
+		AccessToken:  accTkn,
+		RefreshToken: rfrshTkn,
+	})
+}
+
 func (c *Controller) logout(w http.ResponseWriter, r *http.Request) {
 	const op = "http.auth.logout"
 
@@ -220,6 +413,21 @@ func (c *Controller) logout(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, http_lib.RespOk("User logged out succesfully")) //nolint:errcheck
 }
 
+// verify is forward auth endpoint for nginx auth_request and Traefik ForwardAuth.
+// Token is checked by middlewares, so reaching the handler means the request is authenticated
+func (c *Controller) verify(w http.ResponseWriter, r *http.Request) {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+
+	id, _ := claims["sub"].(string)
+	role, _ := claims["role"].(string)
+
+	w.Header().Set("X-User-Id", id)
+	w.Header().Set("X-User-Role", role)
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Authenticated")) //nolint:errcheck
+}
+
 func (c *Controller) confirm(w http.ResponseWriter, r *http.Request) {
 	const op = "http.auth.confirm"
 
@@ -251,6 +459,10 @@ func (c *Controller) confirm(w http.ResponseWriter, r *http.Request) {
 			http_lib.ErrBadRequest(w, r)
 			return
 		}
+		if errors.Is(err, services.ErrCodeExhausted) {
+			http_lib.ErrForbidden(w, r, "Too many wrong codes, request a new one")
+			return
+		}
 
 		http_lib.ErrInternal(w, r)
 		return
@@ -284,6 +496,17 @@ func (c *Controller) resend(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := c.as.ResendCode(r.Context(), rsntCode.Email); err != nil {
+		var rateErr *services.RateLimitedError
+		if errors.As(err, &rateErr) {
+			http_lib.ErrTooManyRequests(w, r, "Confirmation code was sent recently", rateErr.RetryAfter)
+			return
+		}
+		var quotaErr *services.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			http_lib.ErrTooManyRequests(w, r, "Daily limit of confirmation codes is reached", quotaErr.RetryAfter)
+			return
+		}
+
 		if errors.Is(err, services.ErrNotFound) {
 			http_lib.ErrUnauthorized(w, r, "User with given email does't exists")
 			return
@@ -294,6 +517,7 @@ func (c *Controller) resend(w http.ResponseWriter, r *http.Request) {
 		}
 
 		http_lib.ErrInternal(w, r)
+		return
 	}
 
 	render.JSON(w, r, http_lib.RespOk("Confirmation code sent to your email address"))
@@ -322,10 +546,18 @@ func (c *Controller) refresh(w http.ResponseWriter, r *http.Request) {
 
 	accTkn, rfrshTkn, err := c.as.Refresh(r.Context(), rfrshReq.RefreshToken)
 	if err != nil {
-		if errors.Is(err, services.ErrTokenBlacklisted) {
+		if errors.Is(err, services.ErrTokenBlacklisted) || errors.Is(err, services.ErrTokenRevoked) {
 			http_lib.ErrUnauthorized(w, r, "Token revoked")
 			return
 		}
+		if errors.Is(err, services.ErrTokenReused) {
+			http_lib.ErrUnauthorized(w, r, "Token reuse detected, please sign in again")
+			return
+		}
+		if errors.Is(err, services.ErrTokenInvalid) {
+			http_lib.ErrUnauthorized(w, r, "Invalid token")
+			return
+		}
 		if errors.Is(err, services.ErrTokenExpired) {
 			http_lib.ErrUnauthorized(w, r, "Token expired")
 			return
@@ -346,6 +578,355 @@ func (c *Controller) refresh(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (c *Controller) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.forgotPassword"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var forgotReq forgotPasswordRequest
+	if err := render.DecodeJSON(r.Body, &forgotReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(forgotReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.as.ForgotPassword(r.Context(), forgotReq.Email); err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("If the account exists, a password reset token has been sent to your email")) //nolint:errcheck
+}
+
+func (c *Controller) resetPassword(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.resetPassword"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var resetReq resetPasswordRequest
+	if err := render.DecodeJSON(r.Body, &resetReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(resetReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.as.ResetPassword(r.Context(), resetReq.Token, resetReq.Password); err != nil {
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			http_lib.ErrInvalid(w, r, http_lib.FieldErrors{"password": policyErr.Rules})
+			return
+		}
+		if errors.Is(err, services.ErrTokenInvalid) || errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrUnauthorized(w, r, "Invalid or expired reset token")
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Password changed successfully")) //nolint:errcheck
+}
+
 func (t tokensResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+func (m mfaRequiredResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (c *Controller) beginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	assertion, err := c.as.BeginPasskeyLogin(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, assertion)
+}
+
+func (c *Controller) finishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.finishPasskeyLogin"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var pkReq passkeyRequest
+	if err := render.DecodeJSON(r.Body, &pkReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(pkReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	accTkn, rfrshTkn, err := c.as.FinishPasskeyLogin(r.Context(), pkReq.Credential)
+	if err != nil {
+		var mfaErr *services.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			render.Status(r, http.StatusOK)
+			render.Render(w, r, mfaRequiredResponse{ //nolint:errcheck
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+			})
+			return
+		}
+
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+
+		if errors.Is(err, services.ErrPasskeyInvalid) {
+			http_lib.ErrUnauthorized(w, r, "Invalid passkey")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, tokensResponse{ //nolint:errcheck
+		AccessToken:  accTkn,
+		RefreshToken: rfrshTkn,
+	})
+}
+
+func (rr redirectResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (c *Controller) beginFederatedSignIn(w http.ResponseWriter, r *http.Request) {
+	url, err := c.as.BeginFederatedSignIn(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Provider not found")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, redirectResponse{RedirectTo: url}) //nolint:errcheck
+}
+
+func (c *Controller) finishFederatedSignIn(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.finishFederatedSignIn"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var fReq federatedRequest
+	if err := render.DecodeJSON(r.Body, &fReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(fReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	accTkn, rfrshTkn, err := c.as.FinishFederatedSignIn(r.Context(), chi.URLParam(r, "provider"), fReq.Code, fReq.State)
+	if err != nil {
+		var mfaErr *services.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			render.Status(r, http.StatusOK)
+			render.Render(w, r, mfaRequiredResponse{ //nolint:errcheck
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+			})
+			return
+		}
+
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Provider not found")
+			return
+		}
+		if errors.Is(err, services.ErrFederationFailed) {
+			http_lib.ErrUnauthorized(w, r, "Federated sign in failed")
+			return
+		}
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Account with this email already exists")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, tokensResponse{ //nolint:errcheck
+		AccessToken:  accTkn,
+		RefreshToken: rfrshTkn,
+	})
+}
+
+func (c *Controller) unlock(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.unlock"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var unlockReq unlockRequest
+	if err := render.DecodeJSON(r.Body, &unlockReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(unlockReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.as.Unlock(r.Context(), unlockReq.Token); err != nil {
+		if errors.Is(err, services.ErrTokenInvalid) {
+			http_lib.ErrUnauthorized(w, r, "Invalid or expired unlock token")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Account unlocked")) //nolint:errcheck
+}
+
+func (c *Controller) sendMagicLink(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.sendMagicLink"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var linkReq magicLinkRequest
+	if err := render.DecodeJSON(r.Body, &linkReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(linkReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.as.SendMagicLink(r.Context(), linkReq.Email); err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("If the account exists, a sign in link has been sent to your email")) //nolint:errcheck
+}
+
+func (c *Controller) consumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	const op = "http.auth.consumeMagicLink"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var consumeReq consumeMagicLinkRequest
+	if err := render.DecodeJSON(r.Body, &consumeReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(consumeReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	accTkn, rfrshTkn, err := c.as.ConsumeMagicLink(r.Context(), consumeReq.Token)
+	if err != nil {
+		var mfaErr *services.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			render.Status(r, http.StatusOK)
+			render.Render(w, r, mfaRequiredResponse{ //nolint:errcheck
+				MFARequired: true,
+				MFAToken:    mfaErr.Token,
+			})
+			return
+		}
+
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+
+		if errors.Is(err, services.ErrTokenInvalid) ||
+			errors.Is(err, services.ErrTokenExpired) ||
+			errors.Is(err, services.ErrUnexpectedTokenType) {
+			http_lib.ErrUnauthorized(w, r, "Invalid or expired sign in link")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, tokensResponse{ //nolint:errcheck
+		AccessToken:  accTkn,
+		RefreshToken: rfrshTkn,
+	})
+}
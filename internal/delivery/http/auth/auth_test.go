@@ -2,6 +2,9 @@ package auth_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -11,6 +14,12 @@ import (
 	auth_ctrl "e-commerce-users/internal/delivery/http/auth"
 	auth_mock "e-commerce-users/internal/delivery/http/auth/mock"
 	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	password_lib "e-commerce-users/internal/lib/password"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/internal/services"
+	auth_service "e-commerce-users/internal/services/auth"
 	"e-commerce-users/pkg/logger/handlers/slogdiscard"
 
 	"github.com/go-chi/chi/v5"
@@ -26,6 +35,7 @@ func TestController_signUp(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -51,7 +61,7 @@ func TestController_signUp(t *testing.T) {
 			name:                 "Correct input",
 			inputBody:            `{"name": "Jhon", "surname": "Doe", "birthdate": "2000-01-01T00:00:00Z", "email": "jhon@mail.com", "password": "qwerty"}`,
 			expectedStatus:       http.StatusCreated,
-			expectedResponseBody: `{"status": "Ok", "message": "Registration successful. A confirmation code has been sent to your email"}`,
+			expectedResponseBody: `{"status": "Ok", "message": "Check your email to complete registration"}`,
 			mockBehavior: func() {
 				authSrvc.On("SignUp",
 					mock.Anything,
@@ -63,6 +73,30 @@ func TestController_signUp(t *testing.T) {
 				).Return(nil)
 			},
 		},
+		{
+			name:           "Weak password",
+			inputBody:      `{"name": "Jhon", "surname": "Doe", "birthdate": "2000-01-01T00:00:00Z", "email": "jhon@mail.com", "password": "jhon1"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"violations": [
+					{"field": "password", "violations": ["min=8", "classes=3", "not_personal"]}
+				]
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SignUp",
+					mock.Anything,
+					"Jhon",
+					"Doe",
+					"2000-01-01",
+					"jhon@mail.com",
+					"jhon1",
+				).Return(fmt.Errorf("services.auth.SignUp: %w",
+					&services.PasswordPolicyError{Rules: []string{"min=8", "classes=3", "not_personal"}}))
+			},
+		},
 		{
 			name:                 "Empty body",
 			inputBody:            ``,
@@ -113,6 +147,7 @@ func TestController_signIn(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -132,6 +167,7 @@ func TestController_signIn(t *testing.T) {
 		inputBody            string
 		expectedStatus       int
 		expectedResponseBody string
+		expectedRetryAfter   string
 		mockBehavior         func()
 	}{
 		{
@@ -157,6 +193,103 @@ func TestController_signIn(t *testing.T) {
 				)
 			},
 		},
+		{
+			name:           "Second factor required",
+			inputBody:      `{"email": "admin@mail.com", "password": "qwerty"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"mfa_required": true,
+				"mfa_token": "mfa-pending-token"
+			}
+			`,
+			mockBehavior: func() {
+				authSrvc.On(
+					"SignIn",
+					mock.Anything,
+					"admin@mail.com",
+					"qwerty",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignIn: %w", &services.MFARequiredError{Token: "mfa-pending-token"}),
+				)
+			},
+		},
+		{
+			name:                 "Account locked",
+			inputBody:            `{"email": "locked@mail.com", "password": "qwerty"}`,
+			expectedStatus:       http.StatusLocked,
+			expectedResponseBody: `{"status": "Error", "message": "Account is temporarily locked"}`,
+			expectedRetryAfter:   "90",
+			mockBehavior: func() {
+				authSrvc.On(
+					"SignIn",
+					mock.Anything,
+					"locked@mail.com",
+					"qwerty",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignIn: %w", &services.LockedError{RetryAfter: 89500 * time.Millisecond}),
+				)
+			},
+		},
+		{
+			name:                 "Too many attempts from client",
+			inputBody:            `{"email": "victim@mail.com", "password": "qwerty"}`,
+			expectedStatus:       http.StatusTooManyRequests,
+			expectedResponseBody: `{"status": "Error", "message": "Too many requests"}`,
+			expectedRetryAfter:   "600",
+			mockBehavior: func() {
+				authSrvc.On(
+					"SignIn",
+					mock.Anything,
+					"victim@mail.com",
+					"qwerty",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignIn: %w", &services.RateLimitedError{RetryAfter: 10 * time.Minute}),
+				)
+			},
+		},
+		{
+			name:                 "Invalid credentials",
+			inputBody:            `{"email": "jhon@mail.com", "password": "wrong"}`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Invalid credentials"}`,
+			mockBehavior: func() {
+				authSrvc.On(
+					"SignIn",
+					mock.Anything,
+					"jhon@mail.com",
+					"wrong",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignIn: %w", services.ErrInvalidCredentials),
+				)
+			},
+		},
+		{
+			name:                 "Hashing is overloaded",
+			inputBody:            `{"email": "busy@mail.com", "password": "qwerty"}`,
+			expectedStatus:       http.StatusServiceUnavailable,
+			expectedResponseBody: `{"status": "Error", "message": "Service is busy, try again later"}`,
+			mockBehavior: func() {
+				authSrvc.On(
+					"SignIn",
+					mock.Anything,
+					"busy@mail.com",
+					"qwerty",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignIn: %w", services.ErrOverloaded),
+				)
+			},
+		},
 		{
 			name:                 "Empty body",
 			inputBody:            ``,
@@ -192,6 +325,7 @@ func TestController_signIn(t *testing.T) {
 
 			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
 			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			assert.Equal(t, tc.expectedRetryAfter, w.Header().Get("Retry-After"))
 		})
 	}
 }
@@ -203,6 +337,7 @@ func TestController_logout(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -287,6 +422,7 @@ func TestController_confirm(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -329,6 +465,26 @@ func TestController_confirm(t *testing.T) {
 				)
 			},
 		},
+		{
+			name:                 "Wrong code",
+			inputBody:            `{"email": "jhon@mail.com","code": "AAAAAA"}`,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"status": "Error", "message": "Bad request"}`,
+			mockBehavior: func() {
+				authSrvc.On("Confirm", mock.Anything, "jhon@mail.com", "AAAAAA").
+					Return("", "", fmt.Errorf("services.auth.Confirm: %w", services.ErrCode))
+			},
+		},
+		{
+			name:                 "Code attempts exhausted",
+			inputBody:            `{"email": "jhon@mail.com","code": "BBBBBB"}`,
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"status": "Error", "message": "Too many wrong codes, request a new one"}`,
+			mockBehavior: func() {
+				authSrvc.On("Confirm", mock.Anything, "jhon@mail.com", "BBBBBB").
+					Return("", "", fmt.Errorf("services.auth.Confirm: %w", services.ErrCodeExhausted))
+			},
+		},
 		{
 			name:                 "Empty body",
 			inputBody:            ``,
@@ -375,6 +531,7 @@ func TestController_resend(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -394,6 +551,7 @@ func TestController_resend(t *testing.T) {
 		inputBody            string
 		expectedStatus       int
 		expectedResponseBody string
+		expectedRetryAfter   string
 		mockBehavior         func()
 	}{
 		{
@@ -412,6 +570,38 @@ func TestController_resend(t *testing.T) {
 				).Return(nil)
 			},
 		},
+		{
+			name:                 "Within cooldown",
+			inputBody:            `{"email": "recent@mail.com"}`,
+			expectedStatus:       http.StatusTooManyRequests,
+			expectedResponseBody: `{"status": "Error", "message": "Confirmation code was sent recently"}`,
+			expectedRetryAfter:   "42",
+			mockBehavior: func() {
+				authSrvc.On("ResendCode", mock.Anything, "recent@mail.com").
+					Return(fmt.Errorf("services.auth.ResendCode: %w", &services.RateLimitedError{RetryAfter: 42 * time.Second}))
+			},
+		},
+		{
+			name:                 "Daily limit reached",
+			inputBody:            `{"email": "flooded@mail.com"}`,
+			expectedStatus:       http.StatusTooManyRequests,
+			expectedResponseBody: `{"status": "Error", "message": "Daily limit of confirmation codes is reached"}`,
+			expectedRetryAfter:   "3600",
+			mockBehavior: func() {
+				authSrvc.On("ResendCode", mock.Anything, "flooded@mail.com").
+					Return(fmt.Errorf("services.auth.ResendCode: %w", &services.QuotaExceededError{RetryAfter: time.Hour}))
+			},
+		},
+		{
+			name:                 "Internal error",
+			inputBody:            `{"email": "broken@mail.com"}`,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func() {
+				authSrvc.On("ResendCode", mock.Anything, "broken@mail.com").
+					Return(errors.New("some error"))
+			},
+		},
 		{
 			name:                 "Empty body",
 			inputBody:            ``,
@@ -446,6 +636,7 @@ func TestController_resend(t *testing.T) {
 
 			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
 			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			assert.Equal(t, tc.expectedRetryAfter, w.Header().Get("Retry-After"))
 		})
 	}
 }
@@ -457,6 +648,7 @@ func TestController_refresh(t *testing.T) {
 	ctrl := auth_ctrl.New(
 		&auth_ctrl.Config{
 			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
 			TknsCfg: &config.Tokens{
 				Secret:     "secret",
 				AccessTTL:  5 * time.Minute,
@@ -498,6 +690,26 @@ func TestController_refresh(t *testing.T) {
 				)
 			},
 		},
+		{
+			name:           "Reused token",
+			inputBody:      `{"refresh_token": "rotated-refresh-token"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Token reuse detected, please sign in again"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("Refresh",
+					mock.Anything,
+					"rotated-refresh-token",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.Refresh: %w", services.ErrTokenReused),
+				)
+			},
+		},
 		{
 			name:                 "Empty body",
 			inputBody:            ``,
@@ -535,3 +747,1129 @@ func TestController_refresh(t *testing.T) {
 		})
 	}
 }
+
+func TestController_forgotPassword(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg: &config.Tokens{
+				Secret:     "secret",
+				AccessTTL:  5 * time.Minute,
+				RefreshTTL: 15 * time.Minute,
+			},
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Correct input",
+			inputBody:      `{"email": "jhon@mail.com"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"status": "Ok",
+				"message": "If the account exists, a password reset token has been sent to your email"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ForgotPassword",
+					mock.Anything,
+					"jhon@mail.com",
+				).Return(nil)
+			},
+		},
+		{
+			name:                 "Empty body",
+			inputBody:            ``,
+			expectedStatus:       http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"status": "Error","message": "Unprocessable entity"}`,
+			mockBehavior:         func() {},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{"email": "not-an-email"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"email": "field must satisfy 'email' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/password/forgot", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_unlock(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg: &config.Tokens{
+				Secret:     "secret",
+				AccessTTL:  5 * time.Minute,
+				RefreshTTL: 15 * time.Minute,
+			},
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:                 "Correct input",
+			inputBody:            `{"token": "unlock-token"}`,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"status": "Ok", "message": "Account unlocked"}`,
+			mockBehavior: func() {
+				authSrvc.On("Unlock", mock.Anything, "unlock-token").Return(nil)
+			},
+		},
+		{
+			name:                 "Invalid token",
+			inputBody:            `{"token": "used-token"}`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Invalid or expired unlock token"}`,
+			mockBehavior: func() {
+				authSrvc.On("Unlock", mock.Anything, "used-token").
+					Return(fmt.Errorf("services.auth.Unlock: %w", services.ErrTokenInvalid))
+			},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"token": "field must satisfy 'required' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/unlock", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_resetPassword(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg: &config.Tokens{
+				Secret:     "secret",
+				AccessTTL:  5 * time.Minute,
+				RefreshTTL: 15 * time.Minute,
+			},
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Correct input",
+			inputBody:      `{"token": "reset-token", "password": "new-password"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"status": "Ok",
+				"message": "Password changed successfully"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ResetPassword",
+					mock.Anything,
+					"reset-token",
+					"new-password",
+				).Return(nil)
+			},
+		},
+		{
+			name:           "Invalid token",
+			inputBody:      `{"token": "used-token", "password": "new-password"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Invalid or expired reset token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ResetPassword",
+					mock.Anything,
+					"used-token",
+					"new-password",
+				).Return(fmt.Errorf("services.auth.ResetPassword: %w", services.ErrTokenInvalid))
+			},
+		},
+		{
+			name:           "Common password",
+			inputBody:      `{"token": "reset-token", "password": "Password123!"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"violations": [
+					{"field": "password", "violations": ["not_common"]}
+				]
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ResetPassword",
+					mock.Anything,
+					"reset-token",
+					"Password123!",
+				).Return(fmt.Errorf("services.auth.ResetPassword: %w",
+					&services.PasswordPolicyError{Rules: []string{"not_common"}}))
+			},
+		},
+		{
+			name:                 "Empty body",
+			inputBody:            ``,
+			expectedStatus:       http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"status": "Error","message": "Unprocessable entity"}`,
+			mockBehavior:         func() {},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"token": "field must satisfy 'required' constraint",
+					"password": "field must satisfy 'required' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/password/reset", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_signInMFA(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg: &config.Tokens{
+				Secret:     "secret",
+				AccessTTL:  5 * time.Minute,
+				RefreshTTL: 15 * time.Minute,
+			},
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Correct input",
+			inputBody:      `{"mfa_token": "mfa-pending-token", "code": "287082"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"access_token": "new-access-token",
+				"refresh_token": "new-refresh-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SignInMFA",
+					mock.Anything,
+					"mfa-pending-token",
+					"287082",
+					"",
+				).Return(
+					"new-access-token",
+					"new-refresh-token",
+					nil,
+				)
+			},
+		},
+		{
+			name:           "Recovery code",
+			inputBody:      `{"mfa_token": "mfa-pending-token", "recovery_code": "abcde-fghij"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"access_token": "new-access-token",
+				"refresh_token": "new-refresh-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SignInMFA",
+					mock.Anything,
+					"mfa-pending-token",
+					"",
+					"abcde-fghij",
+				).Return(
+					"new-access-token",
+					"new-refresh-token",
+					nil,
+				)
+			},
+		},
+		{
+			name:                 "Account is locked",
+			inputBody:            `{"mfa_token": "mfa-pending-token", "code": "111111"}`,
+			expectedStatus:       http.StatusLocked,
+			expectedResponseBody: `{"status": "Error", "message": "Account is temporarily locked"}`,
+			mockBehavior: func() {
+				authSrvc.On("SignInMFA",
+					mock.Anything,
+					"mfa-pending-token",
+					"111111",
+					"",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignInMFA: %w", &services.LockedError{RetryAfter: time.Minute}),
+				)
+			},
+		},
+		{
+			name:           "Hashing is overloaded",
+			inputBody:      `{"mfa_token": "mfa-pending-token", "recovery_code": "busy-busy"}`,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Service is busy, try again later"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SignInMFA",
+					mock.Anything,
+					"mfa-pending-token",
+					"",
+					"busy-busy",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignInMFA: %w", services.ErrOverloaded),
+				)
+			},
+		},
+		{
+			name:           "Invalid code",
+			inputBody:      `{"mfa_token": "mfa-pending-token", "code": "000000"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Invalid code"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SignInMFA",
+					mock.Anything,
+					"mfa-pending-token",
+					"000000",
+					"",
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.SignInMFA: %w", services.ErrCode),
+				)
+			},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"mfatoken": "field must satisfy 'required' constraint",
+					"code": "field must satisfy 'required_without' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+		{
+			name:           "Both code and recovery code",
+			inputBody:      `{"mfa_token": "mfa-pending-token", "code": "287082", "recovery_code": "abcde-fghij"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"recoverycode": "field must satisfy 'excluded_with' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/sign-in/mfa", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_finishPasskeyLogin(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg: &config.Tokens{
+				Secret:     "secret",
+				AccessTTL:  5 * time.Minute,
+				RefreshTTL: 15 * time.Minute,
+			},
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Correct input",
+			inputBody:      `{"credential": {"id": "verified"}}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"access_token": "new-access-token",
+				"refresh_token": "new-refresh-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("FinishPasskeyLogin",
+					mock.Anything,
+					[]byte(`{"id": "verified"}`),
+				).Return(
+					"new-access-token",
+					"new-refresh-token",
+					nil,
+				)
+			},
+		},
+		{
+			name:           "Second factor required",
+			inputBody:      `{"credential": {"id": "unverified"}}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"mfa_required": true,
+				"mfa_token": "mfa-pending-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("FinishPasskeyLogin",
+					mock.Anything,
+					[]byte(`{"id": "unverified"}`),
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.FinishPasskeyLogin: %w", &services.MFARequiredError{Token: "mfa-pending-token"}),
+				)
+			},
+		},
+		{
+			name:           "Invalid passkey",
+			inputBody:      `{"credential": {"id": "unknown"}}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Invalid passkey"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("FinishPasskeyLogin",
+					mock.Anything,
+					[]byte(`{"id": "unknown"}`),
+				).Return(
+					"",
+					"",
+					fmt.Errorf("services.auth.FinishPasskeyLogin: %w", services.ErrPasskeyInvalid),
+				)
+			},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"credential": "field must satisfy 'required' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/passkey/finish", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_magicLink(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		path                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Send",
+			path:           "/auth/magic-link",
+			inputBody:      `{"email": "jhon@mail.com"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"status": "Ok",
+				"message": "If the account exists, a sign in link has been sent to your email"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("SendMagicLink", mock.Anything, "jhon@mail.com").Return(nil)
+			},
+		},
+		{
+			name:           "Send with invalid email",
+			path:           "/auth/magic-link",
+			inputBody:      `{"email": "jhon"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"email": "field must satisfy 'email' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+		{
+			name:           "Consume",
+			path:           "/auth/magic-link/consume",
+			inputBody:      `{"token": "magic-link-token"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"access_token": "new-access-token",
+				"refresh_token": "new-refresh-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ConsumeMagicLink", mock.Anything, "magic-link-token").
+					Return("new-access-token", "new-refresh-token", nil)
+			},
+		},
+		{
+			name:           "Consume with second factor",
+			path:           "/auth/magic-link/consume",
+			inputBody:      `{"token": "mfa-user-token"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"mfa_required": true,
+				"mfa_token": "mfa-pending-token"
+			}`,
+			mockBehavior: func() {
+				authSrvc.On("ConsumeMagicLink", mock.Anything, "mfa-user-token").
+					Return("", "", fmt.Errorf("services.auth.ConsumeMagicLink: %w", &services.MFARequiredError{Token: "mfa-pending-token"}))
+			},
+		},
+		{
+			name:                 "Consume used link",
+			path:                 "/auth/magic-link/consume",
+			inputBody:            `{"token": "used-token"}`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Invalid or expired sign in link"}`,
+			mockBehavior: func() {
+				authSrvc.On("ConsumeMagicLink", mock.Anything, "used-token").
+					Return("", "", fmt.Errorf("services.auth.ConsumeMagicLink: %w", services.ErrTokenInvalid))
+			},
+		},
+		{
+			name:                 "Consume access token",
+			path:                 "/auth/magic-link/consume",
+			inputBody:            `{"token": "access-token"}`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Invalid or expired sign in link"}`,
+			mockBehavior: func() {
+				authSrvc.On("ConsumeMagicLink", mock.Anything, "access-token").
+					Return("", "", fmt.Errorf("services.auth.ConsumeMagicLink: %w", services.ErrUnexpectedTokenType))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", tc.path, bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_beginFederatedSignIn(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		provider             string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:                 "Correct provider",
+			provider:             "google",
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"redirect_to": "https://accounts.google.com/o/oauth2/v2/auth?state=state"}`,
+			mockBehavior: func() {
+				authSrvc.On("BeginFederatedSignIn", mock.Anything, "google").
+					Return("https://accounts.google.com/o/oauth2/v2/auth?state=state", nil)
+			},
+		},
+		{
+			name:                 "Unknown provider",
+			provider:             "myspace",
+			expectedStatus:       http.StatusNotFound,
+			expectedResponseBody: `{"status": "Error","message": "Provider not found"}`,
+			mockBehavior: func() {
+				authSrvc.On("BeginFederatedSignIn", mock.Anything, "myspace").
+					Return("", fmt.Errorf("services.auth.BeginFederatedSignIn: %w", services.ErrNotFound))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/federated/"+tc.provider+"/begin", nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_finishFederatedSignIn(t *testing.T) {
+	authSrvc := new(auth_mock.AuthService)
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+		},
+	)
+
+	logger := slogdiscard.NewDiscardLogger()
+
+	r.Use(http_lib.Logging(logger))
+
+	r.Mount("/auth", ctrl.Register())
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func()
+	}{
+		{
+			name:           "Correct input",
+			inputBody:      `{"code": "code", "state": "state"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"access_token": "new-access-token",
+				"refresh_token": "new-refresh-token"
+			}
+			`,
+			mockBehavior: func() {
+				authSrvc.On("FinishFederatedSignIn", mock.Anything, "google", "code", "state").
+					Return("new-access-token", "new-refresh-token", nil)
+			},
+		},
+		{
+			name:           "Second factor required",
+			inputBody:      `{"code": "mfa-code", "state": "state"}`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `
+			{
+				"mfa_required": true,
+				"mfa_token": "mfa-pending-token"
+			}
+			`,
+			mockBehavior: func() {
+				authSrvc.On("FinishFederatedSignIn", mock.Anything, "google", "mfa-code", "state").
+					Return("", "", fmt.Errorf("services.auth.FinishFederatedSignIn: %w", &services.MFARequiredError{Token: "mfa-pending-token"}))
+			},
+		},
+		{
+			name:                 "Rejected by provider",
+			inputBody:            `{"code": "bad-code", "state": "state"}`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error","message": "Federated sign in failed"}`,
+			mockBehavior: func() {
+				authSrvc.On("FinishFederatedSignIn", mock.Anything, "google", "bad-code", "state").
+					Return("", "", fmt.Errorf("services.auth.FinishFederatedSignIn: %w", services.ErrFederationFailed))
+			},
+		},
+		{
+			name:                 "Email of not confirmed account",
+			inputBody:            `{"code": "taken-code", "state": "state"}`,
+			expectedStatus:       http.StatusConflict,
+			expectedResponseBody: `{"status": "Error","message": "Account with this email already exists"}`,
+			mockBehavior: func() {
+				authSrvc.On("FinishFederatedSignIn", mock.Anything, "google", "taken-code", "state").
+					Return("", "", fmt.Errorf("services.auth.FinishFederatedSignIn: %w", services.ErrExists))
+			},
+		},
+		{
+			name:           "Invalid body",
+			inputBody:      `{}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `
+			{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {
+					"code": "field must satisfy 'required' constraint",
+					"state": "field must satisfy 'required' constraint"
+				}
+			}`,
+			mockBehavior: func() {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockBehavior()
+
+			req := httptest.NewRequest("POST", "/auth/federated/google/finish", bytes.NewBufferString(tc.inputBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_verify(t *testing.T) {
+	const userID = "3f78ac72-37c1-47ee-9747-bb06214f5310"
+
+	key := jwt_lib.NewHMACKey("", "secret")
+	exp := time.Now().Add(5 * time.Minute)
+
+	accessToken, err := jwt_lib.NewAccessToken(userID, "admin", 1, "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	refreshToken, err := jwt_lib.NewRefreshToken(userID, 1, "jti", "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		token                string
+		expectedStatus       int
+		expectedHeaders      map[string]string
+		expectedResponseBody string
+		mockBehavior         func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator)
+	}{
+		{
+			name:           "Authenticated",
+			token:          accessToken,
+			expectedStatus: http.StatusOK,
+			expectedHeaders: map[string]string{
+				"X-User-Id":   userID,
+				"X-User-Role": "admin",
+			},
+			expectedResponseBody: `{"status": "Ok", "message": "Authenticated"}`,
+			mockBehavior: func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {
+				authSrvc.On("IsBlacklisted", mock.Anything, accessToken).Return(false, nil)
+				vv.On("IsVersionActual", mock.Anything, userID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "No token",
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "No token found"}`,
+			mockBehavior:         func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {},
+		},
+		{
+			name:                 "Refresh token",
+			token:                refreshToken,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Unexpected token type: expected access token"}`,
+			mockBehavior:         func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {},
+		},
+		{
+			name:                 "Blacklisted token",
+			token:                accessToken,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Token revoked"}`,
+			mockBehavior: func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {
+				authSrvc.On("IsBlacklisted", mock.Anything, accessToken).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Outdated version",
+			token:                accessToken,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Token revoked"}`,
+			mockBehavior: func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {
+				authSrvc.On("IsBlacklisted", mock.Anything, accessToken).Return(false, nil)
+				vv.On("IsVersionActual", mock.Anything, userID, 1).Return(false, nil)
+			},
+		},
+		{
+			name:                 "Internal error",
+			token:                accessToken,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(authSrvc *auth_mock.AuthService, vv *auth_mock.VersionValidator) {
+				authSrvc.On("IsBlacklisted", mock.Anything, accessToken).Return(false, errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			authSrvc := auth_mock.NewAuthService(t)
+			vv := auth_mock.NewVersionValidator(t)
+			tc.mockBehavior(authSrvc, vv)
+
+			r := chi.NewRouter()
+			ctrl := auth_ctrl.New(
+				&auth_ctrl.Config{
+					AuthService:      authSrvc,
+					VersionValidator: vv,
+					SigningKeys:      jwt_lib.NewKeySet(key),
+					TknsCfg:          &config.Tokens{},
+				},
+			)
+
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/auth", ctrl.Register())
+
+			req := httptest.NewRequest("GET", "/auth/verify", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode) //nolint:bodyclose
+			for name, value := range tc.expectedHeaders {
+				assert.Equal(t, value, w.Header().Get(name))
+			}
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+// usersByEmail is user repository knowing only users it is given
+type usersByEmail struct {
+	auth_service.UserRepo
+	users map[string]*models.User
+}
+
+func (ur *usersByEmail) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, ok := ur.users[email]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+
+	return user, nil
+}
+
+// lockoutCache keeps attempts counters and locks in memory, time doesn't pass in it
+type lockoutCache struct {
+	auth_service.Cache
+	attempts map[string]int64
+	ttls     map[string]time.Duration
+	locks    map[string]time.Duration
+}
+
+func (c *lockoutCache) IncrAttempts(ctx context.Context, scope, id string, ttl time.Duration) (int64, error) {
+	key := scope + "_" + id
+	if _, ok := c.ttls[key]; !ok {
+		c.ttls[key] = ttl
+	}
+	c.attempts[key]++
+
+	return c.attempts[key], nil
+}
+
+func (c *lockoutCache) GetAttempts(ctx context.Context, scope, id string) (int64, time.Duration, error) {
+	key := scope + "_" + id
+	return c.attempts[key], c.ttls[key], nil
+}
+
+func (c *lockoutCache) ResetAttempts(ctx context.Context, scope, id string) error {
+	delete(c.attempts, scope+"_"+id)
+	delete(c.ttls, scope+"_"+id)
+	return nil
+}
+
+func (c *lockoutCache) LockAccount(ctx context.Context, userID string, ttl time.Duration) error {
+	c.locks[userID] = ttl
+	return nil
+}
+
+func (c *lockoutCache) GetAccountLock(ctx context.Context, userID string) (time.Duration, error) {
+	return c.locks[userID], nil
+}
+
+func (c *lockoutCache) SetActionToken(ctx context.Context, action, token, userID string, ttl time.Duration) error {
+	return nil
+}
+
+type unlockMailer struct {
+	auth_service.Mailer
+}
+
+func (m *unlockMailer) SendUnlockToken(email, token string) error {
+	return nil
+}
+
+func TestController_signInLockout(t *testing.T) {
+	const maxAttempts = 3
+
+	hasher, err := password_lib.New(&config.Password{Algorithm: password_lib.AlgBcrypt, BcryptCost: 4, MaxLength: 72})
+	assert.NoError(t, err)
+
+	passHash, err := hasher.Hash("qwerty")
+	assert.NoError(t, err)
+
+	authSrvc := auth_service.New(&auth_service.Config{
+		Repo: &usersByEmail{users: map[string]*models.User{
+			"jhon@mail.com":      {ID: "3f78ac72-37c1-47ee-9747-bb06214f5310", Email: "jhon@mail.com", PassHash: passHash},
+			"federated@mail.com": {ID: "8f1c6a32-5b0e-4a8e-9d2b-0c7f4e1a9b55", Email: "federated@mail.com"},
+		}},
+		Cache: &lockoutCache{
+			attempts: make(map[string]int64),
+			ttls:     make(map[string]time.Duration),
+			locks:    make(map[string]time.Duration),
+		},
+		Mailer: &unlockMailer{},
+		Hasher: hasher,
+		LockoutCfg: &config.Lockout{
+			MaxAttempts: maxAttempts,
+			Window:      15 * time.Minute,
+			Duration:    time.Minute,
+			MaxDuration: time.Hour,
+		},
+		AntiEnumeration: true,
+	})
+
+	r := chi.NewRouter()
+	ctrl := auth_ctrl.New(
+		&auth_ctrl.Config{
+			AuthService: authSrvc,
+			SigningKeys: jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			TknsCfg:     &config.Tokens{Secret: "secret"},
+		},
+	)
+
+	r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+	r.Mount("/auth", ctrl.Register())
+
+	type response struct {
+		status     int
+		body       string
+		retryAfter string
+	}
+
+	signInAttempts := func(email string) []response {
+		var resps []response
+
+		for range maxAttempts + 1 {
+			body := fmt.Sprintf(`{"email": %q, "password": "wrong-password"}`, email)
+			req := httptest.NewRequest("POST", "/auth/sign-in", bytes.NewBufferString(body))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			resps = append(resps, response{w.Code, w.Body.String(), w.Header().Get("Retry-After")})
+		}
+
+		return resps
+	}
+
+	existing := signInAttempts("jhon@mail.com")
+
+	assert.Equal(t, http.StatusUnauthorized, existing[0].status)
+	assert.Equal(t, http.StatusLocked, existing[maxAttempts-1].status)
+	assert.Equal(t, http.StatusLocked, existing[maxAttempts].status)
+	assert.Equal(t, "60", existing[maxAttempts].retryAfter)
+
+	assert.Equal(t, existing, signInAttempts("unknown@mail.com"))
+	assert.Equal(t, existing, signInAttempts("federated@mail.com"))
+}
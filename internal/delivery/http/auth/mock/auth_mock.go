@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	protocol "github.com/go-webauthn/webauthn/protocol"
 )
 
 // AuthService is an autogenerated mock type for the AuthService type
@@ -13,6 +15,64 @@ type AuthService struct {
 	mock.Mock
 }
 
+// BeginFederatedSignIn provides a mock function with given fields: ctx, provider
+func (_m *AuthService) BeginFederatedSignIn(ctx context.Context, provider string) (string, error) {
+	ret := _m.Called(ctx, provider)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginFederatedSignIn")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, provider)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, provider)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, provider)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BeginPasskeyLogin provides a mock function with given fields: ctx
+func (_m *AuthService) BeginPasskeyLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginPasskeyLogin")
+	}
+
+	var r0 *protocol.CredentialAssertion
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*protocol.CredentialAssertion, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *protocol.CredentialAssertion); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*protocol.CredentialAssertion)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Confirm provides a mock function with given fields: ctx, email, code
 func (_m *AuthService) Confirm(ctx context.Context, email string, code string) (string, string, error) {
 	ret := _m.Called(ctx, email, code)
@@ -48,6 +108,157 @@ func (_m *AuthService) Confirm(ctx context.Context, email string, code string) (
 	return r0, r1, r2
 }
 
+// ConsumeMagicLink provides a mock function with given fields: ctx, token
+func (_m *AuthService) ConsumeMagicLink(ctx context.Context, token string) (string, string, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeMagicLink")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, string, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, token)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FinishFederatedSignIn provides a mock function with given fields: ctx, provider, code, state
+func (_m *AuthService) FinishFederatedSignIn(ctx context.Context, provider string, code string, state string) (string, string, error) {
+	ret := _m.Called(ctx, provider, code, state)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishFederatedSignIn")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (string, string, error)); ok {
+		return rf(ctx, provider, code, state)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) string); ok {
+		r0 = rf(ctx, provider, code, state)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) string); ok {
+		r1 = rf(ctx, provider, code, state)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, string) error); ok {
+		r2 = rf(ctx, provider, code, state)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FinishPasskeyLogin provides a mock function with given fields: ctx, response
+func (_m *AuthService) FinishPasskeyLogin(ctx context.Context, response []byte) (string, string, error) {
+	ret := _m.Called(ctx, response)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishPasskeyLogin")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) (string, string, error)); ok {
+		return rf(ctx, response)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) string); ok {
+		r0 = rf(ctx, response)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []byte) string); ok {
+		r1 = rf(ctx, response)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, []byte) error); ok {
+		r2 = rf(ctx, response)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ForgotPassword provides a mock function with given fields: ctx, email
+func (_m *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ForgotPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IsBlacklisted provides a mock function with given fields: ctx, token
+func (_m *AuthService) IsBlacklisted(ctx context.Context, token string) (bool, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsBlacklisted")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (bool, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Logout provides a mock function with given fields: ctx, accessToken, refreshToken
 func (_m *AuthService) Logout(ctx context.Context, accessToken string, refreshToken string) error {
 	ret := _m.Called(ctx, accessToken, refreshToken)
@@ -119,6 +330,42 @@ func (_m *AuthService) ResendCode(ctx context.Context, email string) error {
 	return r0
 }
 
+// ResetPassword provides a mock function with given fields: ctx, token, password
+func (_m *AuthService) ResetPassword(ctx context.Context, token string, password string) error {
+	ret := _m.Called(ctx, token, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendMagicLink provides a mock function with given fields: ctx, email
+func (_m *AuthService) SendMagicLink(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendMagicLink")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SignIn provides a mock function with given fields: ctx, name, password
 func (_m *AuthService) SignIn(ctx context.Context, name string, password string) (string, string, error) {
 	ret := _m.Called(ctx, name, password)
@@ -154,6 +401,41 @@ func (_m *AuthService) SignIn(ctx context.Context, name string, password string)
 	return r0, r1, r2
 }
 
+// SignInMFA provides a mock function with given fields: ctx, mfaToken, code, recoveryCode
+func (_m *AuthService) SignInMFA(ctx context.Context, mfaToken string, code string, recoveryCode string) (string, string, error) {
+	ret := _m.Called(ctx, mfaToken, code, recoveryCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SignInMFA")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (string, string, error)); ok {
+		return rf(ctx, mfaToken, code, recoveryCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) string); ok {
+		r0 = rf(ctx, mfaToken, code, recoveryCode)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) string); ok {
+		r1 = rf(ctx, mfaToken, code, recoveryCode)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, string) error); ok {
+		r2 = rf(ctx, mfaToken, code, recoveryCode)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // SignUp provides a mock function with given fields: ctx, name, surname, birthdate, email, password
 func (_m *AuthService) SignUp(ctx context.Context, name string, surname string, birthdate string, email string, password string) error {
 	ret := _m.Called(ctx, name, surname, birthdate, email, password)
@@ -172,6 +454,24 @@ func (_m *AuthService) SignUp(ctx context.Context, name string, surname string,
 	return r0
 }
 
+// Unlock provides a mock function with given fields: ctx, token
+func (_m *AuthService) Unlock(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Unlock")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewAuthService creates a new instance of AuthService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewAuthService(t interface {
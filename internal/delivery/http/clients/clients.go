@@ -0,0 +1,176 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const roleAdmin = "admin"
+
+type ClientsService interface {
+	CreateClient(
+		ctx context.Context,
+		name string,
+		redirectURIs []string,
+		scopes []string,
+		public bool,
+	) (*models.OAuthClient, string, error)
+	ListClients(ctx context.Context) ([]models.OAuthClient, error)
+	RotateClientSecret(ctx context.Context, id string) (string, error)
+	DisableClient(ctx context.Context, id string) error
+}
+
+type Controller struct {
+	cs     ClientsService
+	vv     http_lib.VersionValidator
+	keys   *jwt_lib.KeySet
+	valdtr *validator.Validate
+}
+
+type Config struct {
+	ClientsService   ClientsService
+	VersionValidator http_lib.VersionValidator
+	// SigningKeys verify access tokens
+	SigningKeys *jwt_lib.KeySet
+}
+
+type createClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=128"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	Scopes       []string `json:"scopes" validate:"dive,required,excludesall= "`
+	// Public clients have no secret, they can use authorization code flow with PKCE only
+	Public bool `json:"public"`
+}
+
+type clientResponse struct {
+	*models.OAuthClient
+	Secret string `json:"client_secret,omitempty"`
+}
+
+type clientSecretResponse struct {
+	Secret string `json:"client_secret"`
+}
+
+func New(cfg *Config) *Controller {
+	return &Controller{
+		cs:     cfg.ClientsService,
+		vv:     cfg.VersionValidator,
+		keys:   cfg.SigningKeys,
+		valdtr: validator.New(),
+	}
+}
+
+// Register mounts admin endpoints managing OAuth clients. Version is always verified,
+// so demoted or signed out admins lose access right away
+func (c *Controller) Register() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(c.keys.Verifier())
+	r.Use(http_lib.Authenticator)
+	r.Use(http_lib.VersionAuthenticator(c.vv))
+	r.Use(http_lib.RequireRole(roleAdmin))
+	r.Use(http_lib.RequireMFA)
+
+	r.Get("/", c.list)
+	r.Post("/", c.create)
+	r.Post("/{id}/secret", c.rotateSecret)
+	r.Post("/{id}/disable", c.disable)
+
+	return r
+}
+
+func (c *Controller) list(w http.ResponseWriter, r *http.Request) {
+	clients, err := c.cs.ListClients(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, clients)
+}
+
+// create registers client and returns its secret, which can't be shown again
+func (c *Controller) create(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.clients.create"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	var req createClientRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(req); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	client, secret, err := c.cs.CreateClient(r.Context(), req.Name, req.RedirectURIs, req.Scopes, req.Public)
+	if err != nil {
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Client already exists")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, clientResponse{OAuthClient: client, Secret: secret})
+}
+
+// rotateSecret replaces client secret, the previous one stops working immediately
+func (c *Controller) rotateSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := c.cs.RotateClientSecret(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Client not found")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidRequest) {
+			http_lib.ErrConflict(w, r, "Public client has no secret")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, clientSecretResponse{Secret: secret})
+}
+
+func (c *Controller) disable(w http.ResponseWriter, r *http.Request) {
+	if err := c.cs.DisableClient(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Client not found")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Client disabled")) //nolint:errcheck
+}
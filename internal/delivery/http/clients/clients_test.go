@@ -0,0 +1,231 @@
+package clients_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	clients_ctrl "e-commerce-users/internal/delivery/http/clients"
+	clients_mock "e-commerce-users/internal/delivery/http/clients/mock"
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/handlers/slogdiscard"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const adminID = "3f78ac72-37c1-47ee-9747-bb06214f5310"
+
+func TestController(t *testing.T) {
+	key := jwt_lib.NewHMACKey("", "secret")
+	exp := time.Now().Add(5 * time.Minute)
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	adminToken, err := jwt_lib.NewAccessToken(adminID, "admin", 1, "session", true, nil, exp, key)
+	assert.NoError(t, err)
+
+	customerToken, err := jwt_lib.NewAccessToken(adminID, "customer", 1, "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	noMFAToken, err := jwt_lib.NewAccessToken(adminID, "admin", 1, "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	serviceToken, err := jwt_lib.NewServiceToken("orders", "clients:admin", exp, key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		token                string
+		inputBody            string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator)
+	}{
+		{
+			name:           "List clients",
+			method:         http.MethodGet,
+			path:           "/",
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `[{"id": "orders", "name": "Orders", "redirect_uris": [], "scopes": ["payments:write"],
+				"is_active": true, "created_at": "2025-01-01T00:00:00Z"}]`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("ListClients", mock.Anything).Return([]models.OAuthClient{{
+					ID:           "orders",
+					Name:         "Orders",
+					SecretHash:   []byte("hash"),
+					RedirectURIs: []string{},
+					Scopes:       []string{"payments:write"},
+					IsActive:     true,
+					CreatedAt:    createdAt,
+				}}, nil)
+			},
+		},
+		{
+			name:                 "Customer is forbidden",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                customerToken,
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"status": "Error", "message": "Insufficient permissions"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Admin without second factor is forbidden",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                noMFAToken,
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"status": "Error", "message": "Second factor is required"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Service token is rejected",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                serviceToken,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"status": "Error", "message": "Unexpected token type: expected access token"}`,
+			mockBehavior:         func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {},
+		},
+		{
+			name:           "Create confidential client",
+			method:         http.MethodPost,
+			path:           "/",
+			token:          adminToken,
+			inputBody:      `{"name": "Orders", "scopes": ["payments:write"]}`,
+			expectedStatus: http.StatusCreated,
+			expectedResponseBody: `{"id": "orders", "name": "Orders", "redirect_uris": [], "scopes": ["payments:write"],
+				"is_active": true, "created_at": "2025-01-01T00:00:00Z", "client_secret": "s3cret"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("CreateClient", mock.Anything, "Orders", []string(nil), []string{"payments:write"}, false).
+					Return(&models.OAuthClient{
+						ID:           "orders",
+						Name:         "Orders",
+						RedirectURIs: []string{},
+						Scopes:       []string{"payments:write"},
+						IsActive:     true,
+						CreatedAt:    createdAt,
+					}, "s3cret", nil)
+			},
+		},
+		{
+			name:           "Create with invalid fields",
+			method:         http.MethodPost,
+			path:           "/",
+			token:          adminToken,
+			inputBody:      `{"scopes": ["payments:write"]}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponseBody: `{
+				"status": "Error",
+				"message": "Some fields are invalid",
+				"errors": {"name": "field must satisfy 'required' constraint"}
+			}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Rotate secret",
+			method:               http.MethodPost,
+			path:                 "/orders/secret",
+			token:                adminToken,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"client_secret": "n3w"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("RotateClientSecret", mock.Anything, "orders").Return("n3w", nil)
+			},
+		},
+		{
+			name:                 "Rotate secret of public client",
+			method:               http.MethodPost,
+			path:                 "/spa/secret",
+			token:                adminToken,
+			expectedStatus:       http.StatusConflict,
+			expectedResponseBody: `{"status": "Error", "message": "Public client has no secret"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("RotateClientSecret", mock.Anything, "spa").Return("", services.ErrInvalidRequest)
+			},
+		},
+		{
+			name:                 "Disable client",
+			method:               http.MethodPost,
+			path:                 "/orders/disable",
+			token:                adminToken,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"status": "Ok", "message": "Client disabled"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("DisableClient", mock.Anything, "orders").Return(nil)
+			},
+		},
+		{
+			name:                 "Disable unknown client",
+			method:               http.MethodPost,
+			path:                 "/unknown/disable",
+			token:                adminToken,
+			expectedStatus:       http.StatusNotFound,
+			expectedResponseBody: `{"status": "Error", "message": "Client not found"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("DisableClient", mock.Anything, "unknown").Return(services.ErrNotFound)
+			},
+		},
+		{
+			name:                 "Internal error",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                adminToken,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(cs *clients_mock.ClientsService, vv *clients_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				cs.On("ListClients", mock.Anything).Return(nil, errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := clients_mock.NewClientsService(t)
+			vv := clients_mock.NewVersionValidator(t)
+			tc.mockBehavior(cs, vv)
+
+			ctrl := clients_ctrl.New(&clients_ctrl.Config{
+				ClientsService:   cs,
+				VersionValidator: vv,
+				SigningKeys:      jwt_lib.NewKeySet(key),
+			})
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/admin/clients", ctrl.Register())
+
+			req := httptest.NewRequest(tc.method, strings.TrimSuffix("/admin/clients"+tc.path, "/"), strings.NewReader(tc.inputBody))
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package keys
+
+import (
+	"log/slog"
+	"net/http"
+
+	"e-commerce-users/internal/config"
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// jwksMaxAge lets verifiers cache key set, but still notice new keys soon
+const jwksMaxAge = "public, max-age=300"
+
+type Controller struct {
+	keys     *jwt_lib.KeySet
+	oauthCfg *config.OAuth
+	scopes   []string
+}
+
+type Config struct {
+	SigningKeys *jwt_lib.KeySet
+	OAuthCfg    *config.OAuth
+	// Scopes are scopes supported by OpenID Connect provider
+	Scopes []string
+}
+
+// discoveryResponse is OpenID Connect provider metadata
+type discoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+func New(cfg *Config) *Controller {
+	return &Controller{
+		keys:     cfg.SigningKeys,
+		oauthCfg: cfg.OAuthCfg,
+		scopes:   cfg.Scopes,
+	}
+}
+
+// Register mounts endpoints which are served under /.well-known
+func (c *Controller) Register() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Get("/jwks.json", c.jwks)
+	r.Get("/openid-configuration", c.discovery)
+
+	return r
+}
+
+// jwks publishes public keys, so other services can verify issued tokens without signing secret
+func (c *Controller) jwks(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.keys.jwks"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	set, err := jwt_lib.PublicKeySet(c.keys.Keys()...)
+	if err != nil {
+		log.Error("failed to build key set", sl.Err(err))
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", jwksMaxAge)
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, set)
+}
+
+// discovery publishes OpenID Connect provider metadata, so clients can configure themselves
+func (c *Controller) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := c.oauthCfg.Issuer
+
+	w.Header().Set("Cache-Control", jwksMaxAge)
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, discoveryResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{c.keys.Active().Alg()},
+		ScopesSupported:                   c.scopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	})
+}
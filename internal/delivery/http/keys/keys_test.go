@@ -0,0 +1,120 @@
+package keys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/delivery/http/keys"
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/pkg/logger/handlers/slogdiscard"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEd25519Key(t *testing.T, id string) *jwt_lib.Key {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	assert.NoError(t, err)
+
+	key, err := jwt_lib.ParsePrivateKey(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	assert.NoError(t, err)
+
+	return key
+}
+
+func TestJWKS(t *testing.T) {
+	tests := []struct {
+		name         string
+		keys         *jwt_lib.KeySet
+		expectedKids []string
+	}{
+		{
+			name:         "Asymmetric key",
+			keys:         jwt_lib.NewKeySet(newEd25519Key(t, "key-1")),
+			expectedKids: []string{"key-1"},
+		},
+		{
+			name:         "Retired keys are published until pruned",
+			keys:         jwt_lib.NewKeySet(newEd25519Key(t, "key-2"), newEd25519Key(t, "key-1")),
+			expectedKids: []string{"key-2", "key-1"},
+		},
+		{
+			name:         "Shared secret is not published",
+			keys:         jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret")),
+			expectedKids: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := keys.New(&keys.Config{SigningKeys: tc.keys})
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/.well-known", ctrl.Register())
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+
+			var body struct {
+				Keys []map[string]interface{} `json:"keys"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+			kids := make([]string, 0, len(body.Keys))
+			for _, k := range body.Keys {
+				assert.Nil(t, k["d"], "private part must not be published")
+				assert.Equal(t, "sig", k["use"])
+				kids = append(kids, k["kid"].(string))
+			}
+			assert.Equal(t, tc.expectedKids, kids)
+		})
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	ctrl := keys.New(&keys.Config{
+		SigningKeys: jwt_lib.NewKeySet(newEd25519Key(t, "key-1")),
+		OAuthCfg:    &config.OAuth{Issuer: "https://id.example.com"},
+		Scopes:      []string{"openid", "profile", "email"},
+	})
+
+	r := chi.NewRouter()
+	r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+	r.Mount("/.well-known", ctrl.Register())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+	assert.Equal(t, "https://id.example.com", body["issuer"])
+	assert.Equal(t, "https://id.example.com/oauth/authorize", body["authorization_endpoint"])
+	assert.Equal(t, "https://id.example.com/oauth/token", body["token_endpoint"])
+	assert.Equal(t, "https://id.example.com/userinfo", body["userinfo_endpoint"])
+	assert.Equal(t, "https://id.example.com/.well-known/jwks.json", body["jwks_uri"])
+	assert.Equal(t, []interface{}{"EdDSA"}, body["id_token_signing_alg_values_supported"])
+	assert.Equal(t, []interface{}{"S256"}, body["code_challenge_methods_supported"])
+	assert.Equal(t, []interface{}{"openid", "profile", "email"}, body["scopes_supported"])
+}
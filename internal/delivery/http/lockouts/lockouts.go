@@ -0,0 +1,86 @@
+package lockouts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+const roleAdmin = "admin"
+
+type LockoutsService interface {
+	ListLockedAccounts(ctx context.Context) ([]models.Lockout, error)
+	UnlockAccount(ctx context.Context, userID string) error
+}
+
+type Controller struct {
+	ls   LockoutsService
+	vv   http_lib.VersionValidator
+	keys *jwt_lib.KeySet
+}
+
+type Config struct {
+	LockoutsService  LockoutsService
+	VersionValidator http_lib.VersionValidator
+	// SigningKeys verify access tokens
+	SigningKeys *jwt_lib.KeySet
+}
+
+func New(cfg *Config) *Controller {
+	return &Controller{
+		ls:   cfg.LockoutsService,
+		vv:   cfg.VersionValidator,
+		keys: cfg.SigningKeys,
+	}
+}
+
+// Register mounts admin endpoints showing accounts locked after failed sign ins
+func (c *Controller) Register() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(c.keys.Verifier())
+	r.Use(http_lib.Authenticator)
+	r.Use(http_lib.VersionAuthenticator(c.vv))
+	r.Use(http_lib.RequireRole(roleAdmin))
+	r.Use(http_lib.RequireMFA)
+
+	r.Get("/", c.list)
+	r.Post("/{id}/unlock", c.unlock)
+
+	return r
+}
+
+func (c *Controller) list(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := c.ls.ListLockedAccounts(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, lockouts)
+}
+
+// unlock lifts the lock and forgets failed attempts, so the next lock starts from the shortest duration
+func (c *Controller) unlock(w http.ResponseWriter, r *http.Request) {
+	if err := c.ls.UnlockAccount(r.Context(), chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Account is not locked")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Account unlocked")) //nolint:errcheck
+}
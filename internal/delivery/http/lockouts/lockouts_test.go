@@ -0,0 +1,167 @@
+package lockouts_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	lockouts_ctrl "e-commerce-users/internal/delivery/http/lockouts"
+	lockouts_mock "e-commerce-users/internal/delivery/http/lockouts/mock"
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/handlers/slogdiscard"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	adminID = "3f78ac72-37c1-47ee-9747-bb06214f5310"
+	userID  = "8f1c6a32-5b0e-4a8e-9d2b-0c7f4e1a9b55"
+)
+
+func TestController(t *testing.T) {
+	key := jwt_lib.NewHMACKey("", "secret")
+	exp := time.Now().Add(5 * time.Minute)
+	lockedUntil := time.Date(2025, 1, 1, 0, 15, 0, 0, time.UTC)
+
+	adminToken, err := jwt_lib.NewAccessToken(adminID, "admin", 1, "session", true, nil, exp, key)
+	assert.NoError(t, err)
+
+	customerToken, err := jwt_lib.NewAccessToken(adminID, "customer", 1, "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	noMFAToken, err := jwt_lib.NewAccessToken(adminID, "admin", 1, "session", false, nil, exp, key)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		token                string
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator)
+	}{
+		{
+			name:           "List locked accounts",
+			method:         http.MethodGet,
+			path:           "/",
+			token:          adminToken,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `[{"user_id": "8f1c6a32-5b0e-4a8e-9d2b-0c7f4e1a9b55", "email": "jhon@mail.com",
+				"locked_until": "2025-01-01T00:15:00Z"}]`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				ls.On("ListLockedAccounts", mock.Anything).Return([]models.Lockout{{
+					UserID:      userID,
+					Email:       "jhon@mail.com",
+					LockedUntil: lockedUntil,
+				}}, nil)
+			},
+		},
+		{
+			name:                 "No locked accounts",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                adminToken,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `[]`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				ls.On("ListLockedAccounts", mock.Anything).Return([]models.Lockout{}, nil)
+			},
+		},
+		{
+			name:                 "Customer is forbidden",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                customerToken,
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"status": "Error", "message": "Insufficient permissions"}`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Admin without second factor is forbidden",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                noMFAToken,
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"status": "Error", "message": "Second factor is required"}`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+			},
+		},
+		{
+			name:                 "Unlock account",
+			method:               http.MethodPost,
+			path:                 "/" + userID + "/unlock",
+			token:                adminToken,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"status": "Ok", "message": "Account unlocked"}`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				ls.On("UnlockAccount", mock.Anything, userID).Return(nil)
+			},
+		},
+		{
+			name:                 "Unlock not locked account",
+			method:               http.MethodPost,
+			path:                 "/" + userID + "/unlock",
+			token:                adminToken,
+			expectedStatus:       http.StatusNotFound,
+			expectedResponseBody: `{"status": "Error", "message": "Account is not locked"}`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				ls.On("UnlockAccount", mock.Anything, userID).Return(services.ErrNotFound)
+			},
+		},
+		{
+			name:                 "Internal error",
+			method:               http.MethodGet,
+			path:                 "/",
+			token:                adminToken,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(ls *lockouts_mock.LockoutsService, vv *lockouts_mock.VersionValidator) {
+				vv.On("IsVersionActual", mock.Anything, adminID, 1).Return(true, nil)
+				ls.On("ListLockedAccounts", mock.Anything).Return(nil, errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ls := lockouts_mock.NewLockoutsService(t)
+			vv := lockouts_mock.NewVersionValidator(t)
+			tc.mockBehavior(ls, vv)
+
+			ctrl := lockouts_ctrl.New(&lockouts_ctrl.Config{
+				LockoutsService:  ls,
+				VersionValidator: vv,
+				SigningKeys:      jwt_lib.NewKeySet(key),
+			})
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/admin/lockouts", ctrl.Register())
+
+			req := httptest.NewRequest(tc.method, strings.TrimSuffix("/admin/lockouts"+tc.path, "/"), nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"e-commerce-users/internal/config"
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth"
+	"github.com/go-chi/render"
+)
+
+type OAuthService interface {
+	AuthenticateClient(ctx context.Context, clientID, clientSecret string) error
+	Introspect(ctx context.Context, token string) (*models.Introspection, error)
+	ValidateAuthorization(ctx context.Context, req *models.AuthorizationRequest) error
+	Authorize(ctx context.Context, req *models.AuthorizationRequest, accessToken string) (string, error)
+	ExchangeCode(
+		ctx context.Context,
+		clientID string,
+		clientSecret string,
+		code string,
+		redirectURI string,
+		codeVerifier string,
+	) (*models.TokenResponse, error)
+	RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*models.TokenResponse, error)
+	ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*models.TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+}
+
+type Controller struct {
+	os       OAuthService
+	oauthCfg *config.OAuth
+}
+
+type Config struct {
+	OAuthService OAuthService
+	OAuthCfg     *config.OAuth
+}
+
+// errorResponse is RFC 6749 error body, which OAuth clients expect instead of the common response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// redirectResponse is returned instead of 302 to frontends asking for JSON,
+// which can't follow cross-origin redirects with fetch
+type redirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+func New(cfg *Config) *Controller {
+	return &Controller{
+		os:       cfg.OAuthService,
+		oauthCfg: cfg.OAuthCfg,
+	}
+}
+
+// Register mounts endpoints which are served under /oauth
+func (c *Controller) Register() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Get("/authorize", c.authorize)
+	r.Post("/authorize", c.authorize)
+	r.Post("/token", c.token)
+	r.Post("/introspect", c.introspect)
+
+	return r
+}
+
+// RegisterUserInfo mounts OpenID Connect userinfo endpoint, which is served under /userinfo
+func (c *Controller) RegisterUserInfo() *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Get("/", c.userInfo)
+	r.Post("/", c.userInfo)
+
+	return r
+}
+
+// authorize starts authorization code flow. User is identified by first-party access token
+// from header or cookie and is sent to the sign in page if there is none
+func (c *Controller) authorize(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.authorize"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		log.Debug("failed to parse form", sl.Err(err))
+		oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	req := &models.AuthorizationRequest{
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		ResponseType:        r.Form.Get("response_type"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		Nonce:               r.Form.Get("nonce"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+	}
+
+	if err := c.os.ValidateAuthorization(r.Context(), req); err != nil {
+		switch {
+		// Redirect URI can't be trusted, so error is shown to the user instead
+		case errors.Is(err, services.ErrInvalidClient):
+			oauthError(w, r, http.StatusBadRequest, "invalid_client")
+		case errors.Is(err, services.ErrInvalidRedirectURI):
+			oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		case errors.Is(err, services.ErrUnsupportedResponseType):
+			c.redirectError(w, r, req, "unsupported_response_type")
+		case errors.Is(err, services.ErrInvalidScope):
+			c.redirectError(w, r, req, "invalid_scope")
+		case errors.Is(err, services.ErrInvalidRequest):
+			c.redirectError(w, r, req, "invalid_request")
+		default:
+			http_lib.ErrInternal(w, r)
+		}
+
+		return
+	}
+
+	token := jwtauth.TokenFromHeader(r)
+	if token == "" {
+		token = jwtauth.TokenFromCookie(r)
+	}
+
+	code, err := c.os.Authorize(r.Context(), req, token)
+	if err != nil {
+		if errors.Is(err, services.ErrLoginRequired) {
+			if c.oauthCfg.LoginURL == "" {
+				c.redirectError(w, r, req, "login_required")
+				return
+			}
+
+			// Sign in page sends the user back once signed in
+			returnTo := c.oauthCfg.Issuer + "/oauth/authorize?" + r.Form.Encode()
+			redirect(w, r, withQuery(c.oauthCfg.LoginURL, url.Values{"return_to": {returnTo}}))
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	redirect(w, r, withQuery(req.RedirectURI, params))
+}
+
+// token implements token endpoint for authorization_code, refresh_token and client_credentials grants
+func (c *Controller) token(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.token"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		log.Debug("failed to parse form", sl.Err(err))
+		oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	var (
+		resp *models.TokenResponse
+		err  error
+	)
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		resp, err = c.os.ExchangeCode(
+			r.Context(),
+			clientID,
+			clientSecret,
+			r.PostForm.Get("code"),
+			r.PostForm.Get("redirect_uri"),
+			r.PostForm.Get("code_verifier"),
+		)
+	case "refresh_token":
+		resp, err = c.os.RefreshToken(r.Context(), clientID, clientSecret, r.PostForm.Get("refresh_token"))
+	case "client_credentials":
+		resp, err = c.os.ClientCredentials(r.Context(), clientID, clientSecret, r.PostForm.Get("scope"))
+	default:
+		oauthError(w, r, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidClient):
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			oauthError(w, r, http.StatusUnauthorized, "invalid_client")
+		case errors.Is(err, services.ErrInvalidGrant):
+			oauthError(w, r, http.StatusBadRequest, "invalid_grant")
+		case errors.Is(err, services.ErrUnauthorizedClient):
+			oauthError(w, r, http.StatusBadRequest, "unauthorized_client")
+		case errors.Is(err, services.ErrInvalidScope):
+			oauthError(w, r, http.StatusBadRequest, "invalid_scope")
+		case errors.Is(err, services.ErrInvalidRequest):
+			oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		default:
+			http_lib.ErrInternal(w, r)
+		}
+
+		return
+	}
+
+	log.Debug("tokens issued", slog.String("client_id", clientID))
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, resp)
+}
+
+// userInfo returns claims about the user, access token is passed as bearer token (RFC 6750)
+func (c *Controller) userInfo(w http.ResponseWriter, r *http.Request) {
+	token := jwtauth.TokenFromHeader(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		oauthError(w, r, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+
+	info, err := c.os.UserInfo(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTokenInvalid):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			oauthError(w, r, http.StatusUnauthorized, "invalid_token")
+		case errors.Is(err, services.ErrInvalidScope):
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			oauthError(w, r, http.StatusForbidden, "insufficient_scope")
+		default:
+			http_lib.ErrInternal(w, r)
+		}
+
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, info)
+}
+
+// introspect implements RFC 7662. Token is passed as form parameter, client authenticates
+// with HTTP Basic scheme or with client_id and client_secret form parameters
+func (c *Controller) introspect(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.introspect"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		log.Debug("failed to parse form", sl.Err(err))
+		oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if err := c.os.AuthenticateClient(r.Context(), clientID, clientSecret); err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			oauthError(w, r, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		oauthError(w, r, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	info, err := c.os.Introspect(r.Context(), token)
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	log.Debug("token introspected", slog.String("client_id", clientID), slog.Bool("active", info.Active))
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, info)
+}
+
+// redirectError sends authorization error back to the client
+func (c *Controller) redirectError(w http.ResponseWriter, r *http.Request, req *models.AuthorizationRequest, code string) {
+	params := url.Values{"error": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	redirect(w, r, withQuery(req.RedirectURI, params))
+}
+
+func oauthError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	render.Status(r, status)
+	render.JSON(w, r, errorResponse{Error: code})
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, to string) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, redirectResponse{RedirectTo: to})
+		return
+	}
+
+	http.Redirect(w, r, to, http.StatusFound)
+}
+
+// withQuery adds params to URL keeping its own query
+func withQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,477 @@
+package oauth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"e-commerce-users/internal/config"
+	oauth_ctrl "e-commerce-users/internal/delivery/http/oauth"
+	oauth_mock "e-commerce-users/internal/delivery/http/oauth/mock"
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/handlers/slogdiscard"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestController_introspect(t *testing.T) {
+	tests := []struct {
+		name                 string
+		inputBody            string
+		basicAuth            bool
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func(oauthSrvc *oauth_mock.OAuthService)
+	}{
+		{
+			name:                 "Active token, basic auth",
+			inputBody:            `token=access-token&token_type_hint=access_token`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"active": true, "sub": "3f78ac72-37c1-47ee-9747-bb06214f5310", "exp": 1700000000, "token_type": "Bearer"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("AuthenticateClient", mock.Anything, "gateway", "gateway-secret").Return(nil)
+				oauthSrvc.On("Introspect", mock.Anything, "access-token").Return(&models.Introspection{
+					Active:    true,
+					Subject:   "3f78ac72-37c1-47ee-9747-bb06214f5310",
+					ExpiresAt: 1700000000,
+					TokenType: "Bearer",
+				}, nil)
+			},
+		},
+		{
+			name:                 "Inactive token, credentials in body",
+			inputBody:            `token=revoked-token&client_id=gateway&client_secret=gateway-secret`,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"active": false}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("AuthenticateClient", mock.Anything, "gateway", "gateway-secret").Return(nil)
+				oauthSrvc.On("Introspect", mock.Anything, "revoked-token").Return(&models.Introspection{}, nil)
+			},
+		},
+		{
+			name:                 "Invalid client",
+			inputBody:            `token=access-token&client_id=gateway&client_secret=wrong`,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"error": "invalid_client"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("AuthenticateClient", mock.Anything, "gateway", "wrong").
+					Return(services.ErrInvalidClient)
+			},
+		},
+		{
+			name:                 "Missing token",
+			inputBody:            `token_type_hint=access_token`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"error": "invalid_request"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("AuthenticateClient", mock.Anything, "gateway", "gateway-secret").Return(nil)
+			},
+		},
+		{
+			name:                 "Internal error",
+			inputBody:            `token=access-token`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("AuthenticateClient", mock.Anything, "gateway", "gateway-secret").Return(nil)
+				oauthSrvc.On("Introspect", mock.Anything, "access-token").Return(nil, errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthSrvc := oauth_mock.NewOAuthService(t)
+			tc.mockBehavior(oauthSrvc)
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/oauth", oauth_ctrl.New(&oauth_ctrl.Config{OAuthService: oauthSrvc}).Register())
+
+			req := httptest.NewRequest("POST", "/oauth/introspect", strings.NewReader(tc.inputBody))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basicAuth {
+				req.SetBasicAuth("gateway", "gateway-secret")
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_authorize(t *testing.T) {
+	const query = "?client_id=shop&redirect_uri=https%3A%2F%2Fshop.example.com%2Fcb&response_type=code" +
+		"&scope=openid+email&state=xyz&code_challenge=challenge&code_challenge_method=S256"
+
+	authReq := &models.AuthorizationRequest{
+		ClientID:            "shop",
+		RedirectURI:         "https://shop.example.com/cb",
+		ResponseType:        "code",
+		Scope:               "openid email",
+		State:               "xyz",
+		CodeChallenge:       "challenge",
+		CodeChallengeMethod: "S256",
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		token            string
+		acceptJSON       bool
+		loginURL         string
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+		mockBehavior     func(oauthSrvc *oauth_mock.OAuthService)
+	}{
+		{
+			name:             "Ok",
+			query:            query,
+			token:            "access-token",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://shop.example.com/cb?code=auth-code&state=xyz",
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(nil)
+				oauthSrvc.On("Authorize", mock.Anything, authReq, "access-token").Return("auth-code", nil)
+			},
+		},
+		{
+			name:           "Ok, JSON response",
+			query:          query,
+			token:          "access-token",
+			acceptJSON:     true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"redirect_to": "https://shop.example.com/cb?code=auth-code&state=xyz"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(nil)
+				oauthSrvc.On("Authorize", mock.Anything, authReq, "access-token").Return("auth-code", nil)
+			},
+		},
+		{
+			name:           "Not signed in, sent to login page",
+			query:          query,
+			loginURL:       "https://shop.example.com/login",
+			expectedStatus: http.StatusFound,
+			expectedLocation: "https://shop.example.com/login?return_to=" +
+				"https%3A%2F%2Fid.example.com%2Foauth%2Fauthorize%3Fclient_id%3Dshop%26code_challenge%3Dchallenge" +
+				"%26code_challenge_method%3DS256%26redirect_uri%3Dhttps%253A%252F%252Fshop.example.com%252Fcb" +
+				"%26response_type%3Dcode%26scope%3Dopenid%2Bemail%26state%3Dxyz",
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(nil)
+				oauthSrvc.On("Authorize", mock.Anything, authReq, "").Return("", services.ErrLoginRequired)
+			},
+		},
+		{
+			name:             "Not signed in, no login page",
+			query:            query,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://shop.example.com/cb?error=login_required&state=xyz",
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(nil)
+				oauthSrvc.On("Authorize", mock.Anything, authReq, "").Return("", services.ErrLoginRequired)
+			},
+		},
+		{
+			name:             "Invalid scope",
+			query:            query,
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://shop.example.com/cb?error=invalid_scope&state=xyz",
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(services.ErrInvalidScope)
+			},
+		},
+		{
+			name:           "Unregistered redirect uri is not followed",
+			query:          query,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid_request"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(services.ErrInvalidRedirectURI)
+			},
+		},
+		{
+			name:           "Unknown client",
+			query:          query,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid_client"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(services.ErrInvalidClient)
+			},
+		},
+		{
+			name:           "Internal error",
+			query:          query,
+			token:          "access-token",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ValidateAuthorization", mock.Anything, authReq).Return(nil)
+				oauthSrvc.On("Authorize", mock.Anything, authReq, "access-token").Return("", errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthSrvc := oauth_mock.NewOAuthService(t)
+			tc.mockBehavior(oauthSrvc)
+
+			ctrl := oauth_ctrl.New(&oauth_ctrl.Config{
+				OAuthService: oauthSrvc,
+				OAuthCfg:     &config.OAuth{Issuer: "https://id.example.com", LoginURL: tc.loginURL},
+			})
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/oauth", ctrl.Register())
+
+			req := httptest.NewRequest("GET", "/oauth/authorize"+tc.query, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			if tc.acceptJSON {
+				req.Header.Set("Accept", "application/json")
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedLocation, w.Header().Get("Location"))
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestController_token(t *testing.T) {
+	tests := []struct {
+		name                 string
+		inputBody            string
+		basicAuth            bool
+		expectedStatus       int
+		expectedResponseBody string
+		mockBehavior         func(oauthSrvc *oauth_mock.OAuthService)
+	}{
+		{
+			name:           "Authorization code, public client",
+			inputBody:      `grant_type=authorization_code&client_id=spa&code=auth-code&redirect_uri=https%3A%2F%2Fspa.example.com%2Fcb&code_verifier=verifier`,
+			expectedStatus: http.StatusOK,
+			expectedResponseBody: `{"access_token": "access", "token_type": "Bearer", "expires_in": 900,
+				"refresh_token": "refresh", "id_token": "id", "scope": "openid"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ExchangeCode", mock.Anything, "spa", "", "auth-code", "https://spa.example.com/cb", "verifier").
+					Return(&models.TokenResponse{
+						AccessToken:  "access",
+						TokenType:    "Bearer",
+						ExpiresIn:    900,
+						RefreshToken: "refresh",
+						IDToken:      "id",
+						Scope:        "openid",
+					}, nil)
+			},
+		},
+		{
+			name:                 "Refresh token, basic auth",
+			inputBody:            `grant_type=refresh_token&refresh_token=refresh`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"access_token": "access", "token_type": "Bearer", "expires_in": 900, "refresh_token": "new-refresh"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("RefreshToken", mock.Anything, "shop", "shop-secret", "refresh").
+					Return(&models.TokenResponse{
+						AccessToken:  "access",
+						TokenType:    "Bearer",
+						ExpiresIn:    900,
+						RefreshToken: "new-refresh",
+					}, nil)
+			},
+		},
+		{
+			name:                 "Invalid client",
+			inputBody:            `grant_type=authorization_code&code=auth-code`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"error": "invalid_client"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ExchangeCode", mock.Anything, "shop", "shop-secret", "auth-code", "", "").
+					Return(nil, services.ErrInvalidClient)
+			},
+		},
+		{
+			name:                 "Invalid grant",
+			inputBody:            `grant_type=authorization_code&client_id=spa&code=used-code`,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"error": "invalid_grant"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ExchangeCode", mock.Anything, "spa", "", "used-code", "", "").
+					Return(nil, services.ErrInvalidGrant)
+			},
+		},
+		{
+			name:                 "Client credentials",
+			inputBody:            `grant_type=client_credentials&scope=payments%3Awrite`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"access_token": "service", "token_type": "Bearer", "expires_in": 900, "scope": "payments:write"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ClientCredentials", mock.Anything, "shop", "shop-secret", "payments:write").
+					Return(&models.TokenResponse{
+						AccessToken: "service",
+						TokenType:   "Bearer",
+						ExpiresIn:   900,
+						Scope:       "payments:write",
+					}, nil)
+			},
+		},
+		{
+			name:                 "Client credentials, scope not allowed",
+			inputBody:            `grant_type=client_credentials&scope=users%3Aadmin`,
+			basicAuth:            true,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"error": "invalid_scope"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ClientCredentials", mock.Anything, "shop", "shop-secret", "users:admin").
+					Return(nil, services.ErrInvalidScope)
+			},
+		},
+		{
+			name:                 "Client credentials, public client",
+			inputBody:            `grant_type=client_credentials&client_id=spa`,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"error": "unauthorized_client"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("ClientCredentials", mock.Anything, "spa", "", "").
+					Return(nil, services.ErrUnauthorizedClient)
+			},
+		},
+		{
+			name:                 "Unsupported grant type",
+			inputBody:            `grant_type=password&username=user&password=pass`,
+			expectedStatus:       http.StatusBadRequest,
+			expectedResponseBody: `{"error": "unsupported_grant_type"}`,
+			mockBehavior:         func(oauthSrvc *oauth_mock.OAuthService) {},
+		},
+		{
+			name:                 "Internal error",
+			inputBody:            `grant_type=refresh_token&client_id=spa&refresh_token=refresh`,
+			expectedStatus:       http.StatusInternalServerError,
+			expectedResponseBody: `{"status": "Error", "message": "Internal error"}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("RefreshToken", mock.Anything, "spa", "", "refresh").Return(nil, errors.New("some error"))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthSrvc := oauth_mock.NewOAuthService(t)
+			tc.mockBehavior(oauthSrvc)
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/oauth", oauth_ctrl.New(&oauth_ctrl.Config{OAuthService: oauthSrvc}).Register())
+
+			req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(tc.inputBody))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basicAuth {
+				req.SetBasicAuth("shop", "shop-secret")
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestController_userInfo(t *testing.T) {
+	tests := []struct {
+		name                 string
+		token                string
+		expectedStatus       int
+		expectedResponseBody string
+		expectedChallenge    string
+		mockBehavior         func(oauthSrvc *oauth_mock.OAuthService)
+	}{
+		{
+			name:                 "Ok",
+			token:                "access-token",
+			expectedStatus:       http.StatusOK,
+			expectedResponseBody: `{"sub": "3f78ac72-37c1-47ee-9747-bb06214f5310", "email": "john@example.com", "email_verified": true}`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("UserInfo", mock.Anything, "access-token").Return(map[string]interface{}{
+					"sub":            "3f78ac72-37c1-47ee-9747-bb06214f5310",
+					"email":          "john@example.com",
+					"email_verified": true,
+				}, nil)
+			},
+		},
+		{
+			name:                 "No token",
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"error": "invalid_token"}`,
+			expectedChallenge:    `Bearer`,
+			mockBehavior:         func(oauthSrvc *oauth_mock.OAuthService) {},
+		},
+		{
+			name:                 "Revoked token",
+			token:                "revoked-token",
+			expectedStatus:       http.StatusUnauthorized,
+			expectedResponseBody: `{"error": "invalid_token"}`,
+			expectedChallenge:    `Bearer error="invalid_token"`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("UserInfo", mock.Anything, "revoked-token").Return(nil, services.ErrTokenInvalid)
+			},
+		},
+		{
+			name:                 "No openid scope",
+			token:                "first-party-token",
+			expectedStatus:       http.StatusForbidden,
+			expectedResponseBody: `{"error": "insufficient_scope"}`,
+			expectedChallenge:    `Bearer error="insufficient_scope", scope="openid"`,
+			mockBehavior: func(oauthSrvc *oauth_mock.OAuthService) {
+				oauthSrvc.On("UserInfo", mock.Anything, "first-party-token").Return(nil, services.ErrInvalidScope)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oauthSrvc := oauth_mock.NewOAuthService(t)
+			tc.mockBehavior(oauthSrvc)
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Mount("/userinfo", oauth_ctrl.New(&oauth_ctrl.Config{OAuthService: oauthSrvc}).RegisterUserInfo())
+
+			req := httptest.NewRequest("GET", "/userinfo", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
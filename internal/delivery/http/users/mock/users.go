@@ -7,6 +7,8 @@ import (
 	models "e-commerce-users/internal/models"
 
 	mock "github.com/stretchr/testify/mock"
+
+	protocol "github.com/go-webauthn/webauthn/protocol"
 )
 
 // UsersService is an autogenerated mock type for the UsersService type
@@ -14,6 +16,271 @@ type UsersService struct {
 	mock.Mock
 }
 
+// ActivateTOTP provides a mock function with given fields: ctx, id, code
+func (_m *UsersService) ActivateTOTP(ctx context.Context, id string, code string) ([]string, error) {
+	ret := _m.Called(ctx, id, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ActivateTOTP")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]string, error)); ok {
+		return rf(ctx, id, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []string); ok {
+		r0 = rf(ctx, id, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BeginIdentityLink provides a mock function with given fields: ctx, id, provider
+func (_m *UsersService) BeginIdentityLink(ctx context.Context, id string, provider string) (string, error) {
+	ret := _m.Called(ctx, id, provider)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginIdentityLink")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (string, error)); ok {
+		return rf(ctx, id, provider)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, id, provider)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, provider)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// BeginPasskeyRegistration provides a mock function with given fields: ctx, id
+func (_m *UsersService) BeginPasskeyRegistration(ctx context.Context, id string) (*protocol.CredentialCreation, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BeginPasskeyRegistration")
+	}
+
+	var r0 *protocol.CredentialCreation
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*protocol.CredentialCreation, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *protocol.CredentialCreation); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*protocol.CredentialCreation)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePassword provides a mock function with given fields: ctx, id, currentPassword, newPassword
+func (_m *UsersService) ChangePassword(ctx context.Context, id string, currentPassword string, newPassword string) error {
+	ret := _m.Called(ctx, id, currentPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, currentPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountRecoveryCodes provides a mock function with given fields: ctx, id
+func (_m *UsersService) CountRecoveryCodes(ctx context.Context, id string) (int, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountRecoveryCodes")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (int, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) int); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeletePasskey provides a mock function with given fields: ctx, id, passkeyID
+func (_m *UsersService) DeletePasskey(ctx context.Context, id string, passkeyID []byte) error {
+	ret := _m.Called(ctx, id, passkeyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePasskey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []byte) error); ok {
+		r0 = rf(ctx, id, passkeyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisableTOTP provides a mock function with given fields: ctx, id, code
+func (_m *UsersService) DisableTOTP(ctx context.Context, id string, code string) error {
+	ret := _m.Called(ctx, id, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, code)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnrollTOTP provides a mock function with given fields: ctx, id
+func (_m *UsersService) EnrollTOTP(ctx context.Context, id string) (string, string, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnrollTOTP")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, string, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, id)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FinishIdentityLink provides a mock function with given fields: ctx, id, provider, code, state
+func (_m *UsersService) FinishIdentityLink(ctx context.Context, id string, provider string, code string, state string) (*models.FederatedIdentity, error) {
+	ret := _m.Called(ctx, id, provider, code, state)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishIdentityLink")
+	}
+
+	var r0 *models.FederatedIdentity
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) (*models.FederatedIdentity, error)); ok {
+		return rf(ctx, id, provider, code, state)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) *models.FederatedIdentity); ok {
+		r0 = rf(ctx, id, provider, code, state)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.FederatedIdentity)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string) error); ok {
+		r1 = rf(ctx, id, provider, code, state)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishPasskeyRegistration provides a mock function with given fields: ctx, id, name, response
+func (_m *UsersService) FinishPasskeyRegistration(ctx context.Context, id string, name string, response []byte) (*models.Passkey, error) {
+	ret := _m.Called(ctx, id, name, response)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishPasskeyRegistration")
+	}
+
+	var r0 *models.Passkey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []byte) (*models.Passkey, error)); ok {
+		return rf(ctx, id, name, response)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []byte) *models.Passkey); ok {
+		r0 = rf(ctx, id, name, response)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Passkey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []byte) error); ok {
+		r1 = rf(ctx, id, name, response)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetProfile provides a mock function with given fields: ctx, id
 func (_m *UsersService) GetProfile(ctx context.Context, id string) (*models.User, error) {
 	ret := _m.Called(ctx, id)
@@ -44,6 +311,244 @@ func (_m *UsersService) GetProfile(ctx context.Context, id string) (*models.User
 	return r0, r1
 }
 
+// IsVersionActual provides a mock function with given fields: ctx, id, version
+func (_m *UsersService) IsVersionActual(ctx context.Context, id string, version int) (bool, error) {
+	ret := _m.Called(ctx, id, version)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsVersionActual")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) (bool, error)); ok {
+		return rf(ctx, id, version)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) bool); ok {
+		r0 = rf(ctx, id, version)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, id, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListLoginMethods provides a mock function with given fields: ctx, id
+func (_m *UsersService) ListLoginMethods(ctx context.Context, id string) (*models.LoginMethods, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListLoginMethods")
+	}
+
+	var r0 *models.LoginMethods
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*models.LoginMethods, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.LoginMethods); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.LoginMethods)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPasskeys provides a mock function with given fields: ctx, id
+func (_m *UsersService) ListPasskeys(ctx context.Context, id string) ([]models.Passkey, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListPasskeys")
+	}
+
+	var r0 []models.Passkey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]models.Passkey, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.Passkey); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Passkey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSessions provides a mock function with given fields: ctx, id, currentSessionID
+func (_m *UsersService) ListSessions(ctx context.Context, id string, currentSessionID string) ([]models.Session, error) {
+	ret := _m.Called(ctx, id, currentSessionID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListSessions")
+	}
+
+	var r0 []models.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]models.Session, error)); ok {
+		return rf(ctx, id, currentSessionID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []models.Session); ok {
+		r0 = rf(ctx, id, currentSessionID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, currentSessionID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegenerateRecoveryCodes provides a mock function with given fields: ctx, id
+func (_m *UsersService) RegenerateRecoveryCodes(ctx context.Context, id string) ([]string, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegenerateRecoveryCodes")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]string, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemovePassword provides a mock function with given fields: ctx, id, currentPassword
+func (_m *UsersService) RemovePassword(ctx context.Context, id string, currentPassword string) error {
+	ret := _m.Called(ctx, id, currentPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemovePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, currentPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeAllSessions provides a mock function with given fields: ctx, id
+func (_m *UsersService) RevokeAllSessions(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAllSessions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeSession provides a mock function with given fields: ctx, id, sessionID
+func (_m *UsersService) RevokeSession(ctx context.Context, id string, sessionID string) error {
+	ret := _m.Called(ctx, id, sessionID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeSession")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPassword provides a mock function with given fields: ctx, id, sessionID, password
+func (_m *UsersService) SetPassword(ctx context.Context, id string, sessionID string, password string) error {
+	ret := _m.Called(ctx, id, sessionID, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, id, sessionID, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnlinkIdentity provides a mock function with given fields: ctx, id, identityID
+func (_m *UsersService) UnlinkIdentity(ctx context.Context, id string, identityID string) error {
+	ret := _m.Called(ctx, id, identityID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnlinkIdentity")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, id, identityID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUsersService creates a new instance of UsersService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUsersService(t interface {
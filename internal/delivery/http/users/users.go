@@ -2,36 +2,143 @@ package users
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"e-commerce-users/internal/config"
 	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
 	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/sl"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth"
 	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-webauthn/webauthn/protocol"
 )
 
 type UsersService interface {
 	GetProfile(ctx context.Context, id string) (*models.User, error)
+	ChangePassword(ctx context.Context, id, currentPassword, newPassword string) error
+	RevokeAllSessions(ctx context.Context, id string) error
+	ListSessions(ctx context.Context, id, currentSessionID string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, id, sessionID string) error
+	EnrollTOTP(ctx context.Context, id string) (string, string, error)
+	ActivateTOTP(ctx context.Context, id, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, id, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, id string) ([]string, error)
+	CountRecoveryCodes(ctx context.Context, id string) (int, error)
+	BeginPasskeyRegistration(ctx context.Context, id string) (*protocol.CredentialCreation, error)
+	FinishPasskeyRegistration(ctx context.Context, id, name string, response []byte) (*models.Passkey, error)
+	ListPasskeys(ctx context.Context, id string) ([]models.Passkey, error)
+	DeletePasskey(ctx context.Context, id string, passkeyID []byte) error
+	ListLoginMethods(ctx context.Context, id string) (*models.LoginMethods, error)
+	SetPassword(ctx context.Context, id, sessionID, password string) error
+	RemovePassword(ctx context.Context, id, currentPassword string) error
+	BeginIdentityLink(ctx context.Context, id, provider string) (string, error)
+	FinishIdentityLink(ctx context.Context, id, provider, code, state string) (*models.FederatedIdentity, error)
+	UnlinkIdentity(ctx context.Context, id, identityID string) error
+	IsVersionActual(ctx context.Context, id string, version int) (bool, error)
 }
 
 type Controller struct {
 	us      UsersService
 	tknsCfg config.Tokens
+	keys    *jwt_lib.KeySet
+	valdtr  *validator.Validate
 }
 
 type Config struct {
 	UsrSrvc UsersService
 	TknsCfg config.Tokens
+	// SigningKeys verify access tokens
+	SigningKeys *jwt_lib.KeySet
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,nefield=CurrentPassword"`
+}
+
+type setPasswordRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type removePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+type linkIdentityRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+type redirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type loginMethodsResponse struct {
+	Password   bool                       `json:"password"`
+	Identities []models.FederatedIdentity `json:"identities"`
+	Passkeys   []passkeyResponse          `json:"passkeys"`
+}
+
+type totpCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type totpEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type recoveryCodesResponse struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type recoveryCodesCountResponse struct {
+	Remaining int `json:"remaining"`
+}
+
+type passkeyRegistrationRequest struct {
+	Name       string          `json:"name" validate:"required,max=64"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+type passkeyResponse struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	BackupState    bool       `json:"backup_state"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+func newPasskeyResponse(p *models.Passkey) passkeyResponse {
+	return passkeyResponse{
+		ID:             base64.RawURLEncoding.EncodeToString(p.ID),
+		Name:           p.Name,
+		Transports:     p.Transports,
+		BackupEligible: p.BackupEligible,
+		BackupState:    p.BackupState,
+		CreatedAt:      p.CreatedAt,
+		LastUsedAt:     p.LastUsedAt,
+	}
 }
 
 func New(cfg *Config) *Controller {
 	return &Controller{
 		us:      cfg.UsrSrvc,
 		tknsCfg: cfg.TknsCfg,
+		keys:    cfg.SigningKeys,
+		valdtr:  validator.New(),
 	}
 }
 
@@ -39,12 +146,41 @@ func (c *Controller) Register() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Route("/me", func(r chi.Router) {
-		r.Use(jwtauth.Verifier(
-			jwtauth.New("HS256", []byte(c.tknsCfg.Secret), nil),
-		))
+		r.Use(c.keys.Verifier())
 		r.Use(http_lib.Authenticator)
+		if c.tknsCfg.VerifyVersion {
+			r.Use(http_lib.VersionAuthenticator(c.us))
+		}
 
 		r.Get("/", c.getProfile)
+		r.Post("/password", c.changePassword)
+		r.Put("/password", c.setPassword)
+		r.Delete("/password", c.removePassword)
+		r.Get("/sessions", c.listSessions)
+		r.Delete("/sessions/{id}", c.revokeSession)
+		r.Post("/sessions/revoke-all", c.revokeAllSessions)
+
+		r.Route("/mfa/totp", func(r chi.Router) {
+			r.Post("/", c.enrollTOTP)
+			r.Post("/verify", c.activateTOTP)
+			r.Post("/disable", c.disableTOTP)
+		})
+		r.Route("/mfa/recovery-codes", func(r chi.Router) {
+			r.Get("/", c.countRecoveryCodes)
+			r.Post("/", c.regenerateRecoveryCodes)
+		})
+		r.Route("/passkeys", func(r chi.Router) {
+			r.Get("/", c.listPasskeys)
+			r.Post("/begin", c.beginPasskeyRegistration)
+			r.Post("/finish", c.finishPasskeyRegistration)
+			r.Delete("/{id}", c.deletePasskey)
+		})
+		r.Route("/identities", func(r chi.Router) {
+			r.Get("/", c.listIdentities)
+			r.Post("/{provider}/begin", c.beginIdentityLink)
+			r.Post("/{provider}/finish", c.finishIdentityLink)
+			r.Delete("/{id}", c.unlinkIdentity)
+		})
 	})
 
 	return r
@@ -74,3 +210,660 @@ func (c *Controller) getProfile(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, user)
 }
+
+func (c *Controller) changePassword(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.users.changePassword"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	var changeReq changePasswordRequest
+	if err := render.DecodeJSON(r.Body, &changeReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(changeReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.us.ChangePassword(r.Context(), id, changeReq.CurrentPassword, changeReq.NewPassword); err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			http_lib.ErrForbidden(w, r, "Invalid current password")
+			return
+		}
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			http_lib.ErrInvalid(w, r, http_lib.FieldErrors{"newpassword": policyErr.Rules})
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Password changed successfully. Please sign in again")) //nolint:errcheck
+}
+
+func (c *Controller) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	if err := c.us.RevokeAllSessions(r.Context(), id); err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("All sessions revoked")) //nolint:errcheck
+}
+
+func (c *Controller) listSessions(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	sessions, err := c.us.ListSessions(r.Context(), id, sessionID)
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.JSON(w, r, sessions)
+}
+
+func (c *Controller) revokeSession(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	if err := c.us.RevokeSession(r.Context(), id, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Session not found")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Session revoked")) //nolint:errcheck
+}
+
+func (c *Controller) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	secret, uri, err := c.us.EnrollTOTP(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Two-factor authentication already enabled")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, totpEnrollResponse{
+		Secret: secret,
+		URI:    uri,
+	})
+}
+
+func (c *Controller) activateTOTP(w http.ResponseWriter, r *http.Request) {
+	var codes []string
+	activate := func(ctx context.Context, id, code string) error {
+		var err error
+		codes, err = c.us.ActivateTOTP(ctx, id, code)
+		return err
+	}
+
+	if !c.handleTOTPCode(w, r, "controllers.users.activateTOTP", activate) {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, recoveryCodesResponse{Codes: codes})
+}
+
+func (c *Controller) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	if !c.handleTOTPCode(w, r, "controllers.users.disableTOTP", c.us.DisableTOTP) {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Two-factor authentication disabled")) //nolint:errcheck
+}
+
+// handleTOTPCode decodes TOTP code from request and passes it to the given service action.
+// Writes error response and returns false if action has not succeeded
+func (c *Controller) handleTOTPCode(
+	w http.ResponseWriter,
+	r *http.Request,
+	op string,
+	action func(ctx context.Context, id, code string) error,
+) bool {
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return false
+	}
+
+	id := claims["sub"].(string)
+
+	var codeReq totpCodeRequest
+	if err := render.DecodeJSON(r.Body, &codeReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return false
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(codeReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return false
+	}
+
+	if err := action(r.Context(), id, codeReq.Code); err != nil {
+		if errors.Is(err, services.ErrCode) {
+			http_lib.ErrForbidden(w, r, "Invalid code")
+			return false
+		}
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Two-factor authentication is not set up")
+			return false
+		}
+		if errors.Is(err, services.ErrNoActionRequired) {
+			http_lib.ErrConflict(w, r, "Two-factor authentication already enabled")
+			return false
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return false
+		}
+
+		http_lib.ErrInternal(w, r)
+		return false
+	}
+
+	return true
+}
+
+func (c *Controller) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	codes, err := c.us.RegenerateRecoveryCodes(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Two-factor authentication is not set up")
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, recoveryCodesResponse{Codes: codes})
+}
+
+func (c *Controller) countRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	count, err := c.us.CountRecoveryCodes(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Two-factor authentication is not set up")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, recoveryCodesCountResponse{Remaining: count})
+}
+
+func (c *Controller) listPasskeys(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	passkeys, err := c.us.ListPasskeys(r.Context(), id)
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	resp := make([]passkeyResponse, 0, len(passkeys))
+	for i := range passkeys {
+		resp = append(resp, newPasskeyResponse(&passkeys[i]))
+	}
+
+	render.JSON(w, r, resp)
+}
+
+func (c *Controller) beginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	creation, err := c.us.BeginPasskeyRegistration(r.Context(), id)
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, creation)
+}
+
+func (c *Controller) finishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.users.finishPasskeyRegistration"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	var pkReq passkeyRegistrationRequest
+	if err := render.DecodeJSON(r.Body, &pkReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(pkReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	pk, err := c.us.FinishPasskeyRegistration(r.Context(), id, pkReq.Name, pkReq.Credential)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyInvalid) {
+			http_lib.ErrForbidden(w, r, "Invalid passkey")
+			return
+		}
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Passkey already registered")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, newPasskeyResponse(pk))
+}
+
+func (c *Controller) deletePasskey(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	passkeyID, err := base64.RawURLEncoding.DecodeString(chi.URLParam(r, "id"))
+	if err != nil {
+		http_lib.ErrNotFound(w, r, "Passkey not found")
+		return
+	}
+
+	if err := c.us.DeletePasskey(r.Context(), id, passkeyID); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Passkey not found")
+			return
+		}
+		if errors.Is(err, services.ErrLastLoginMethod) {
+			http_lib.ErrConflict(w, r, "Can't remove the last login method")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Passkey deleted")) //nolint:errcheck
+}
+
+func (c *Controller) listIdentities(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	methods, err := c.us.ListLoginMethods(r.Context(), id)
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	resp := loginMethodsResponse{
+		Password:   methods.Password,
+		Identities: make([]models.FederatedIdentity, 0, len(methods.Identities)),
+		Passkeys:   make([]passkeyResponse, 0, len(methods.Passkeys)),
+	}
+
+	resp.Identities = append(resp.Identities, methods.Identities...)
+	for i := range methods.Passkeys {
+		resp.Passkeys = append(resp.Passkeys, newPasskeyResponse(&methods.Passkeys[i]))
+	}
+
+	render.JSON(w, r, resp)
+}
+
+func (c *Controller) setPassword(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.users.setPassword"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	var setReq setPasswordRequest
+	if err := render.DecodeJSON(r.Body, &setReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(setReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.us.SetPassword(r.Context(), id, sessionID, setReq.Password); err != nil {
+		if errors.Is(err, services.ErrReauthRequired) {
+			http_lib.ErrForbidden(w, r, "Sign in again to set password")
+			return
+		}
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			http_lib.ErrInvalid(w, r, http_lib.FieldErrors{"password": policyErr.Rules})
+			return
+		}
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Password is already set")
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, http_lib.RespOk("Password set. Please sign in again")) //nolint:errcheck
+}
+
+func (c *Controller) removePassword(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.users.removePassword"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	var removeReq removePasswordRequest
+	if err := render.DecodeJSON(r.Body, &removeReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(removeReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	if err := c.us.RemovePassword(r.Context(), id, removeReq.CurrentPassword); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Password is not set")
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			http_lib.ErrForbidden(w, r, "Invalid current password")
+			return
+		}
+		var lockedErr *services.LockedError
+		if errors.As(err, &lockedErr) {
+			http_lib.ErrLocked(w, r, "Account is temporarily locked", lockedErr.RetryAfter)
+			return
+		}
+		if errors.Is(err, services.ErrOverloaded) {
+			http_lib.ErrServiceUnavailable(w, r, "Service is busy, try again later")
+			return
+		}
+		if errors.Is(err, services.ErrLastLoginMethod) {
+			http_lib.ErrConflict(w, r, "Can't remove the last login method")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Password removed. Please sign in again")) //nolint:errcheck
+}
+
+func (rr redirectResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (c *Controller) beginIdentityLink(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	url, err := c.us.BeginIdentityLink(r.Context(), id, chi.URLParam(r, "provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Provider not found")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, redirectResponse{RedirectTo: url}) //nolint:errcheck
+}
+
+func (c *Controller) finishIdentityLink(w http.ResponseWriter, r *http.Request) {
+	const op = "controllers.users.finishIdentityLink"
+
+	log := http_lib.GetCtxLogger(r.Context())
+	log = log.With(slog.String("op", op))
+
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	var linkReq linkIdentityRequest
+	if err := render.DecodeJSON(r.Body, &linkReq); err != nil {
+		log.Debug("failed to parse JSON", sl.Err(err))
+		http_lib.ErrUnprocessableEntity(w, r)
+		return
+	}
+
+	defer r.Body.Close() //nolint:errcheck
+
+	if err := c.valdtr.Struct(linkReq); err != nil {
+		log.Error("some fields are invalid", sl.Err(err))
+		http_lib.ErrInvalid(w, r, err)
+		return
+	}
+
+	identity, err := c.us.FinishIdentityLink(r.Context(), id, chi.URLParam(r, "provider"), linkReq.Code, linkReq.State)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Provider not found")
+			return
+		}
+		// Not 401, which would tell client its access token is invalid
+		if errors.Is(err, services.ErrFederationFailed) {
+			http_lib.ErrForbidden(w, r, "Federated sign in failed")
+			return
+		}
+		if errors.Is(err, services.ErrExists) {
+			http_lib.ErrConflict(w, r, "Identity is already linked")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, identity)
+}
+
+func (c *Controller) unlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	id := claims["sub"].(string)
+
+	if err := c.us.UnlinkIdentity(r.Context(), id, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			http_lib.ErrNotFound(w, r, "Identity not found")
+			return
+		}
+		if errors.Is(err, services.ErrLastLoginMethod) {
+			http_lib.ErrConflict(w, r, "Can't remove the last login method")
+			return
+		}
+
+		http_lib.ErrInternal(w, r)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, http_lib.RespOk("Identity unlinked")) //nolint:errcheck
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 	"unicode"
@@ -19,8 +20,15 @@ type contextKey struct {
 
 var (
 	CtxKeyLogger = &contextKey{"logger"}
+	CtxKeyClient = &contextKey{"client"}
 )
 
+// ClientInfo describes device which performs request
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
 var (
 	ErrLoggerNotFound = errors.New("logger not found in context")
 )
@@ -29,6 +37,33 @@ func GetCtxLogger(ctx context.Context) *slog.Logger {
 	return ctx.Value(CtxKeyLogger).(*slog.Logger)
 }
 
+// GetCtxClient returns client info put by Client middleware or empty one if it is absent
+func GetCtxClient(ctx context.Context) *ClientInfo {
+	client, ok := ctx.Value(CtxKeyClient).(*ClientInfo)
+	if !ok {
+		return &ClientInfo{}
+	}
+
+	return client
+}
+
+// Client middleware puts user agent and IP address of the client into request context
+func Client(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := context.WithValue(r.Context(), CtxKeyClient, &ClientInfo{
+			UserAgent: r.UserAgent(),
+			IP:        ip,
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // TraceID middleware adds traceID to each request if it is not set
 func TraceID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,7 +107,7 @@ func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 
 func Authenticator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, _, err := jwtauth.FromContext(r.Context())
+		token, claims, err := jwtauth.FromContext(r.Context())
 
 		if err != nil {
 			msg := []rune(err.Error())
@@ -86,7 +121,129 @@ func Authenticator(next http.Handler) http.Handler {
 			return
 		}
 
+		// Refresh and mfa_pending tokens are signed with the same key and must not grant access
+		if claims["type"] != "access" {
+			ErrUnauthorized(w, r, "Unexpected token type: expected access token")
+			return
+		}
+
+		// Tokens issued to OAuth clients are only good for userinfo and resource servers
+		if _, ok := claims["client_id"]; ok {
+			ErrUnauthorized(w, r, "Token issued to OAuth client is not accepted")
+			return
+		}
+
 		// Token is authenticated, pass it through
 		next.ServeHTTP(w, r)
 	})
 }
+
+type BlacklistChecker interface {
+	IsBlacklisted(ctx context.Context, token string) (bool, error)
+}
+
+// BlacklistAuthenticator middleware rejects tokens revoked by logout. Must be used after Authenticator
+func BlacklistAuthenticator(bc BlacklistChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := jwtauth.TokenFromHeader(r)
+			if token == "" {
+				token = jwtauth.TokenFromCookie(r)
+			}
+
+			blacklisted, err := bc.IsBlacklisted(r.Context(), token)
+			if err != nil {
+				ErrInternal(w, r)
+				return
+			}
+
+			if blacklisted {
+				ErrUnauthorized(w, r, "Token revoked")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type VersionValidator interface {
+	IsVersionActual(ctx context.Context, id string, version int) (bool, error)
+}
+
+// VersionAuthenticator middleware rejects tokens which version claim
+// doesn't match the actual user version. Must be used after Authenticator
+func VersionAuthenticator(vv VersionValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				ErrUnauthorized(w, r, "Token is unauthorized")
+				return
+			}
+
+			id, ok := claims["sub"].(string)
+			if !ok {
+				ErrUnauthorized(w, r, "Token is unauthorized")
+				return
+			}
+
+			version, ok := claims["version"].(float64)
+			if !ok {
+				ErrUnauthorized(w, r, "Token is unauthorized")
+				return
+			}
+
+			actual, err := vv.IsVersionActual(r.Context(), id, int(version))
+			if err != nil {
+				ErrInternal(w, r)
+				return
+			}
+
+			if !actual {
+				ErrUnauthorized(w, r, "Token revoked")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole middleware lets through only tokens with given role claim. Must be used after Authenticator
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				ErrUnauthorized(w, r, "Token is unauthorized")
+				return
+			}
+
+			if claims["role"] != role {
+				ErrForbidden(w, r, "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireMFA middleware lets through only tokens issued after the second factor. Must be used after Authenticator
+func RequireMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			ErrUnauthorized(w, r, "Token is unauthorized")
+			return
+		}
+
+		if mfa, _ := claims["mfa"].(bool); !mfa {
+			ErrForbidden(w, r, "Second factor is required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
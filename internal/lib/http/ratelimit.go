@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// RateLimiter counts requests in storage shared by all replicas
+type RateLimiter interface {
+	// Allow counts request under key and reports whether it fits limit within sliding window,
+	// how many requests are left and time until the oldest counted request leaves the window
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error)
+}
+
+// RateLimitKey identifies who is limited. Requests with empty key are not limited
+type RateLimitKey func(r *http.Request) string
+
+type RateLimitPolicy struct {
+	// Name separates counters of route groups
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    RateLimitKey
+}
+
+// RateLimit middleware rejects requests over policy limit with 429 and sets RateLimit-* headers.
+// Zero limit disables it
+func RateLimit(rl RateLimiter, p RateLimitPolicy) func(http.Handler) http.Handler {
+	policy := fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		if p.Limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := p.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, remaining, reset, err := rl.Allow(r.Context(), p.Name+":"+key, p.Limit, p.Window)
+			if err != nil {
+				// Limiter outage must not take the service down, requests go unlimited until it recovers
+				GetCtxLogger(r.Context()).Error("failed to check rate limit", slog.String("error", err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(p.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", retryAfterSeconds(reset))
+
+			if !allowed {
+				ErrTooManyRequests(w, r, "Too many requests", reset)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyByIP limits every client IP separately. Requires Client middleware
+func KeyByIP(r *http.Request) string {
+	ip := GetCtxClient(r.Context()).IP
+	if ip == "" {
+		return ""
+	}
+
+	return "ip:" + ip
+}
+
+// KeyBySubject limits every user of valid access token separately and other requests by IP.
+// Requires token verifier before it, otherwise falls back to IP
+func KeyBySubject(r *http.Request) string {
+	token, _, err := jwtauth.FromContext(r.Context())
+	if err == nil && token != nil && jwt.Validate(token) == nil && token.Subject() != "" {
+		return "sub:" + token.Subject()
+	}
+
+	return KeyByIP(r)
+}
+
+// KeyByRoute limits all clients of route together
+func KeyByRoute(r *http.Request) string {
+	return "route:" + r.Method + " " + r.URL.Path
+}
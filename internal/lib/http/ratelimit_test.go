@@ -0,0 +1,159 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/pkg/logger/handlers/slogdiscard"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedWindow is in-memory limiter counting requests of key until reset
+type fixedWindow struct {
+	counts map[string]int
+	err    error
+}
+
+func (fw *fixedWindow) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	if fw.err != nil {
+		return false, 0, 0, fw.err
+	}
+
+	if fw.counts[key] >= limit {
+		return false, 0, window, nil
+	}
+
+	fw.counts[key]++
+
+	return true, limit - fw.counts[key], window, nil
+}
+
+func TestRateLimit(t *testing.T) {
+	key := jwt_lib.NewHMACKey("", "secret")
+
+	token, err := jwt_lib.NewAccessToken("user-id", "customer", 1, "session", false, nil, time.Now().Add(time.Minute), key)
+	assert.NoError(t, err)
+
+	type request struct {
+		remoteAddr string
+		token      string
+	}
+
+	tests := []struct {
+		name               string
+		policy             http_lib.RateLimitPolicy
+		limiterErr         error
+		requests           []request
+		expectedStatuses   []int
+		expectedRemaining  string
+		expectedRetryAfter string
+	}{
+		{
+			name:   "Limit by IP",
+			policy: http_lib.RateLimitPolicy{Name: "auth", Limit: 2, Window: time.Minute, Key: http_lib.KeyByIP},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000"},
+				{remoteAddr: "10.0.0.1:1001"},
+				{remoteAddr: "10.0.0.2:1000"},
+				{remoteAddr: "10.0.0.1:1002"},
+			},
+			expectedStatuses:   []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			expectedRemaining:  "0",
+			expectedRetryAfter: "60",
+		},
+		{
+			name:   "Limit by subject",
+			policy: http_lib.RateLimitPolicy{Name: "users", Limit: 2, Window: time.Minute, Key: http_lib.KeyBySubject},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", token: token},
+				{remoteAddr: "10.0.0.2:1000", token: token},
+				{remoteAddr: "10.0.0.3:1000", token: token},
+			},
+			expectedStatuses:   []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			expectedRemaining:  "0",
+			expectedRetryAfter: "60",
+		},
+		{
+			name:   "Invalid token is limited by IP",
+			policy: http_lib.RateLimitPolicy{Name: "users", Limit: 1, Window: time.Minute, Key: http_lib.KeyBySubject},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", token: "forged"},
+				{remoteAddr: "10.0.0.1:1000", token: token},
+				{remoteAddr: "10.0.0.1:1000", token: "another-forged"},
+			},
+			expectedStatuses:   []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			expectedRemaining:  "0",
+			expectedRetryAfter: "60",
+		},
+		{
+			name:   "Limit by route",
+			policy: http_lib.RateLimitPolicy{Name: "route", Limit: 3, Window: time.Minute, Key: http_lib.KeyByRoute},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000"},
+				{remoteAddr: "10.0.0.2:1000"},
+			},
+			expectedStatuses:  []int{http.StatusOK, http.StatusOK},
+			expectedRemaining: "1",
+		},
+		{
+			name:              "Disabled",
+			policy:            http_lib.RateLimitPolicy{Name: "auth", Limit: 0, Window: time.Minute, Key: http_lib.KeyByIP},
+			requests:          []request{{remoteAddr: "10.0.0.1:1000"}, {remoteAddr: "10.0.0.1:1000"}},
+			expectedStatuses:  []int{http.StatusOK, http.StatusOK},
+			expectedRemaining: "",
+		},
+		{
+			name:              "Limiter failure lets requests through",
+			policy:            http_lib.RateLimitPolicy{Name: "auth", Limit: 1, Window: time.Minute, Key: http_lib.KeyByIP},
+			limiterErr:        errors.New("connection refused"),
+			requests:          []request{{remoteAddr: "10.0.0.1:1000"}, {remoteAddr: "10.0.0.1:1000"}},
+			expectedStatuses:  []int{http.StatusOK, http.StatusOK},
+			expectedRemaining: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := &fixedWindow{counts: map[string]int{}, err: tc.limiterErr}
+
+			r := chi.NewRouter()
+			r.Use(http_lib.Client)
+			r.Use(http_lib.Logging(slogdiscard.NewDiscardLogger()))
+			r.Use(jwt_lib.NewKeySet(key).Verifier())
+			r.Use(http_lib.RateLimit(limiter, tc.policy))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			var w *httptest.ResponseRecorder
+			for i, rq := range tc.requests {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = rq.remoteAddr
+				if rq.token != "" {
+					req.Header.Set("Authorization", "Bearer "+rq.token)
+				}
+				w = httptest.NewRecorder()
+
+				r.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.expectedStatuses[i], w.Code, "request %d", i)
+			}
+
+			assert.Equal(t, tc.expectedRemaining, w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, tc.expectedRetryAfter, w.Header().Get("Retry-After"))
+			if tc.expectedRemaining != "" {
+				assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+				assert.Equal(t, fmt.Sprintf("%d;w=60", tc.policy.Limit), w.Header().Get("RateLimit-Policy"))
+			}
+		})
+	}
+}
@@ -2,8 +2,13 @@ package http
 
 import (
 	"fmt"
+	"maps"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
@@ -15,14 +20,28 @@ const (
 )
 
 type Response struct {
-	Status  string           `json:"status"`
-	Message string           `json:"message,omitempty"`
-	Error   string           `json:"error,omitempty"`
-	Errors  validationErrors `json:"errors,omitempty"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Errors     validationErrors  `json:"errors,omitempty"`
+	Violations []fieldViolations `json:"violations,omitempty"`
 }
 
 type validationErrors map[string]string
 
+// fieldViolations lists every rule violated by field
+type fieldViolations struct {
+	Field      string   `json:"field"`
+	Violations []string `json:"violations"`
+}
+
+// FieldErrors maps invalid field to rules it violates, for checks made beyond request validation
+type FieldErrors map[string][]string
+
+func (fe FieldErrors) Error() string {
+	return "invalid fields"
+}
+
 func RespOk(msg string) *Response {
 	return &Response{
 		Status:  StatusOk,
@@ -77,21 +96,78 @@ func ErrUnauthorized(w http.ResponseWriter, r *http.Request, msg string) {
 	})
 }
 
-func ErrInvalid(w http.ResponseWriter, r *http.Request, err error) {
-	errs := make(validationErrors)
+func ErrNotFound(w http.ResponseWriter, r *http.Request, msg string) {
+	render.Status(r, http.StatusNotFound)
+	render.Render(w, r, Response{ //nolint:errcheck
+		Status:  StatusErr,
+		Message: msg,
+	})
+}
 
-	for _, e := range err.(validator.ValidationErrors) {
-		errs[strings.ToLower(e.Field())] = fmt.Sprintf("field must satisfy '%s' constraint", e.Tag())
-	}
+func ErrForbidden(w http.ResponseWriter, r *http.Request, msg string) {
+	render.Status(r, http.StatusForbidden)
+	render.Render(w, r, Response{ //nolint:errcheck
+		Status:  StatusErr,
+		Message: msg,
+	})
+}
 
-	render.Status(r, http.StatusBadRequest)
+func ErrServiceUnavailable(w http.ResponseWriter, r *http.Request, msg string) {
+	render.Status(r, http.StatusServiceUnavailable)
 	render.Render(w, r, Response{ //nolint:errcheck
 		Status:  StatusErr,
-		Message: "Some fields are invalid",
-		Errors:  errs,
+		Message: msg,
+	})
+}
+
+func ErrLocked(w http.ResponseWriter, r *http.Request, msg string, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	render.Status(r, http.StatusLocked)
+	render.Render(w, r, Response{ //nolint:errcheck
+		Status:  StatusErr,
+		Message: msg,
 	})
 }
 
+func ErrTooManyRequests(w http.ResponseWriter, r *http.Request, msg string, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	render.Status(r, http.StatusTooManyRequests)
+	render.Render(w, r, Response{ //nolint:errcheck
+		Status:  StatusErr,
+		Message: msg,
+	})
+}
+
+// retryAfterSeconds formats Retry-After header value, rounding up so client doesn't retry too early
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// ErrInvalid responds with constraints violated by fields. Err is validator.ValidationErrors, reported
+// in errors map with the first violated constraint of every field, or FieldErrors, reported in violations
+// list with every violated rule
+func ErrInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	resp := Response{
+		Status:  StatusErr,
+		Message: "Some fields are invalid",
+	}
+
+	switch err := err.(type) {
+	case validator.ValidationErrors:
+		resp.Errors = make(validationErrors)
+		for _, e := range err {
+			resp.Errors[strings.ToLower(e.Field())] = fmt.Sprintf("field must satisfy '%s' constraint", e.Tag())
+		}
+	case FieldErrors:
+		for _, field := range slices.Sorted(maps.Keys(err)) {
+			resp.Violations = append(resp.Violations, fieldViolations{Field: field, Violations: err[field]})
+		}
+	}
+
+	render.Status(r, http.StatusBadRequest)
+	render.Render(w, r, resp) //nolint:errcheck
+}
+
 func (resp Response) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
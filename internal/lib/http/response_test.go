@@ -0,0 +1,42 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	http_lib "e-commerce-users/internal/lib/http"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrInvalidFieldErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	http_lib.ErrInvalid(w, r, http_lib.FieldErrors{
+		"password":    {"min=8", "classes=3"},
+		"newpassword": {"not_common"},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp struct {
+		Errors     map[string]string `json:"errors"`
+		Violations []struct {
+			Field      string   `json:"field"`
+			Violations []string `json:"violations"`
+		} `json:"violations"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Empty(t, resp.Errors)
+	if assert.Len(t, resp.Violations, 2) {
+		assert.Equal(t, "newpassword", resp.Violations[0].Field)
+		assert.Equal(t, []string{"not_common"}, resp.Violations[0].Violations)
+
+		assert.Equal(t, "password", resp.Violations[1].Field)
+		assert.Equal(t, []string{"min=8", "classes=3"}, resp.Violations[1].Violations)
+	}
+}
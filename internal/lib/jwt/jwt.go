@@ -16,24 +16,67 @@ var (
 	ErrExpired       = errors.New("expired")
 )
 
+// Grant describes OAuth client the tokens were issued to and scope the user consented to.
+// Tokens issued to first-party frontends carry no grant
+type Grant struct {
+	ClientID string
+	Scope    string
+}
+
+func (g *Grant) apply(claims jwt.MapClaims) jwt.MapClaims {
+	if g != nil {
+		claims["client_id"] = g.ClientID
+		claims["scope"] = g.Scope
+	}
+
+	return claims
+}
+
+// GrantFromClaims returns grant carried by token or nil for first-party token
+func GrantFromClaims(claims jwt.MapClaims) *Grant {
+	clientID, ok := claims["client_id"].(string)
+	if !ok {
+		return nil
+	}
+
+	scope, _ := claims["scope"].(string)
+
+	return &Grant{
+		ClientID: clientID,
+		Scope:    scope,
+	}
+}
+
+// NewAccessToken generates access token of first-party session or, with grant, of OAuth client.
+// Client tokens have their own type and no role or mfa claims, so first-party endpoints reject them
 func NewAccessToken(
 	id string,
 	role string,
 	version int,
+	sessionID string,
+	mfa bool,
+	grant *Grant,
 	exp time.Time,
-	secret string,
+	key *Key,
 ) (string, error) {
 	const op = "lib.jwt.NewAccessToken"
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"sub":     id,
-		"role":    role,
 		"version": version,
-		"type":    "access",
+		"sid":     sessionID,
 		"exp":     exp.Unix(),
-	})
+	}
+
+	if grant != nil {
+		claims["type"] = "client_access"
+	} else {
+		claims["type"] = "access"
+		claims["role"] = role
+		claims["mfa"] = mfa
+	}
 
-	tkn, err := token.SignedString([]byte(secret))
+	tkn, err := key.sign(grant.apply(claims))
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
@@ -41,22 +84,133 @@ func NewAccessToken(
 	return tkn, nil
 }
 
+// NewRefreshToken generates refresh token with unique jti, which belongs to the given token family
 func NewRefreshToken(
 	id string,
 	version int,
+	jti string,
+	familyID string,
+	mfa bool,
+	grant *Grant,
 	exp time.Time,
-	secret string,
+	key *Key,
 ) (string, error) {
 	const op = "lib.jwt.NewRefreshToken"
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	tkn, err := key.sign(grant.apply(jwt.MapClaims{
 		"sub":     id,
 		"version": version,
+		"jti":     jti,
+		"fam":     familyID,
+		"mfa":     mfa,
 		"type":    "refresh",
 		"exp":     exp.Unix(),
+	}))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tkn, nil
+}
+
+// NewMFAToken generates short-lived token proving that user passed the first factor
+func NewMFAToken(
+	id string,
+	jti string,
+	exp time.Time,
+	key *Key,
+) (string, error) {
+	const op = "lib.jwt.NewMFAToken"
+
+	tkn, err := key.sign(jwt.MapClaims{
+		"sub":  id,
+		"jti":  jti,
+		"type": "mfa_pending",
+		"exp":  exp.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tkn, nil
+}
+
+// NewMagicLinkToken generates token emailed to user, which signs in without password.
+// Jti is kept in cache until the token is used, so it works only once
+func NewMagicLinkToken(
+	id string,
+	jti string,
+	exp time.Time,
+	key *Key,
+) (string, error) {
+	const op = "lib.jwt.NewMagicLinkToken"
+
+	tkn, err := key.sign(jwt.MapClaims{
+		"sub":  id,
+		"jti":  jti,
+		"type": "magic_link",
+		"exp":  exp.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tkn, nil
+}
+
+// NewServiceToken generates access token issued to client itself by client_credentials grant.
+// It carries no user, so user endpoints reject it by its type
+func NewServiceToken(
+	clientID string,
+	scope string,
+	exp time.Time,
+	key *Key,
+) (string, error) {
+	const op = "lib.jwt.NewServiceToken"
+
+	tkn, err := key.sign(jwt.MapClaims{
+		"client_id": clientID,
+		"scope":     scope,
+		"type":      "service",
+		"iat":       time.Now().Unix(),
+		"exp":       exp.Unix(),
 	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tkn, nil
+}
+
+// NewIDToken generates OpenID Connect ID token for the client. Profile claims are added as is.
+// Type claim keeps ID token from being accepted as access token
+func NewIDToken(
+	issuer string,
+	id string,
+	clientID string,
+	nonce string,
+	profile map[string]interface{},
+	exp time.Time,
+	key *Key,
+) (string, error) {
+	const op = "lib.jwt.NewIDToken"
 
-	tkn, err := token.SignedString([]byte(secret))
+	claims := jwt.MapClaims{}
+	for k, v := range profile {
+		claims[k] = v
+	}
+
+	claims["iss"] = issuer
+	claims["sub"] = id
+	claims["aud"] = clientID
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = exp.Unix()
+	claims["type"] = "id"
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	tkn, err := key.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
@@ -75,11 +229,20 @@ func FromCtx(ctx context.Context) (map[string]interface{}, error) {
 	return claims, nil
 }
 
-func FromString(token, secret string) (jwt.MapClaims, error) {
+// FromString verifies token signed by one of the keys of the set and returns its claims.
+// Key is selected by kid header. Tokens signed with unknown key or another algorithm are rejected
+func FromString(token string, keys *KeySet) (jwt.MapClaims, error) {
 	const op = "lib.jwt.FromString"
 
 	tkn, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := keys.Lookup(kid)
+		if !ok || t.Method.Alg() != key.Alg() {
+			return nil, ErrInvalid
+		}
+
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		var jwtErr *jwt.ValidationError
@@ -87,7 +250,7 @@ func FromString(token, secret string) (jwt.MapClaims, error) {
 			if jwtErr.Errors == jwt.ValidationErrorExpired {
 				return nil, fmt.Errorf("%s: %w", op, ErrExpired)
 			}
-			if jwtErr.Errors == jwt.ValidationErrorSignatureInvalid {
+			if jwtErr.Errors == jwt.ValidationErrorSignatureInvalid || errors.Is(jwtErr.Inner, ErrInvalid) {
 				return nil, fmt.Errorf("%s: %w", op, ErrInvalid)
 			}
 		}
@@ -115,3 +278,35 @@ func GetClaim(claims jwt.MapClaims, claimName string) (string, error) {
 		return "", fmt.Errorf("%s: unsupported claim type %T", op, v)
 	}
 }
+
+func GetIntClaim(claims jwt.MapClaims, claimName string) (int, error) {
+	const op = "lib.jwt.GetIntClaim"
+
+	claim, ok := claims[claimName]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, ErrClaimNotFound)
+	}
+
+	v, ok := claim.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s: unsupported claim type %T", op, claim)
+	}
+
+	return int(v), nil
+}
+
+func GetBoolClaim(claims jwt.MapClaims, claimName string) (bool, error) {
+	const op = "lib.jwt.GetBoolClaim"
+
+	claim, ok := claims[claimName]
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, ErrClaimNotFound)
+	}
+
+	v, ok := claim.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s: unsupported claim type %T", op, claim)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,70 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantClaims(t *testing.T) {
+	keys := jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret"))
+	grant := &jwt_lib.Grant{ClientID: "shop", Scope: "openid email"}
+
+	tkn, err := jwt_lib.NewRefreshToken("user", 1, "jti", "fam", false, grant, time.Now().Add(time.Minute), keys.Active())
+	assert.NoError(t, err)
+
+	claims, err := jwt_lib.FromString(tkn, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, grant, jwt_lib.GrantFromClaims(claims))
+
+	tkn, err = jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, time.Now().Add(time.Minute), keys.Active())
+	assert.NoError(t, err)
+
+	claims, err = jwt_lib.FromString(tkn, keys)
+	assert.NoError(t, err)
+	assert.Nil(t, jwt_lib.GrantFromClaims(claims), "first-party token carries no grant")
+	assert.Equal(t, "access", claims["type"])
+
+	tkn, err = jwt_lib.NewAccessToken("user", "admin", 1, "sid", true, grant, time.Now().Add(time.Minute), keys.Active())
+	assert.NoError(t, err)
+
+	claims, err = jwt_lib.FromString(tkn, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, grant, jwt_lib.GrantFromClaims(claims))
+	assert.Equal(t, "client_access", claims["type"])
+	assert.NotContains(t, claims, "role")
+	assert.NotContains(t, claims, "mfa")
+}
+
+func TestNewIDToken(t *testing.T) {
+	key := newEd25519Key(t, "ed")
+	profile := map[string]interface{}{"email": "john@example.com", "sub": "spoofed"}
+
+	tkn, err := jwt_lib.NewIDToken("https://id.example.com", "user", "shop", "n-0S6", profile, time.Now().Add(time.Minute), key)
+	assert.NoError(t, err)
+
+	claims, err := jwt_lib.FromString(tkn, jwt_lib.NewKeySet(key))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://id.example.com", claims["iss"])
+	assert.Equal(t, "user", claims["sub"], "profile can't override registered claims")
+	assert.Equal(t, "shop", claims["aud"])
+	assert.Equal(t, "n-0S6", claims["nonce"])
+	assert.Equal(t, "john@example.com", claims["email"])
+	assert.Equal(t, "id", claims["type"])
+}
+
+func TestNewServiceToken(t *testing.T) {
+	keys := jwt_lib.NewKeySet(jwt_lib.NewHMACKey("", "secret"))
+
+	tkn, err := jwt_lib.NewServiceToken("orders", "payments:write", time.Now().Add(time.Minute), keys.Active())
+	assert.NoError(t, err)
+
+	claims, err := jwt_lib.FromString(tkn, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, "service", claims["type"])
+	assert.Nil(t, claims["sub"], "service token has no user")
+	assert.Equal(t, &jwt_lib.Grant{ClientID: "orders", Scope: "payments:write"}, jwt_lib.GrantFromClaims(claims))
+}
@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"e-commerce-users/internal/config"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/golang-jwt/jwt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const minRSAKeyBits = 2048
+
+var (
+	ErrNoKey          = errors.New("neither secret nor private key configured")
+	ErrUnsupportedKey = errors.New("unsupported key")
+)
+
+// Key is a token signing key. Its ID is put to kid header of issued tokens.
+// Asymmetric keys (RS256, EdDSA) let other services verify tokens with public part published in JWKS
+type Key struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	ja        *jwtauth.JWTAuth
+}
+
+// NewHMACKey creates symmetric HS256 key
+func NewHMACKey(id, secret string) *Key {
+	return newKey(id, jwt.SigningMethodHS256, []byte(secret), []byte(secret))
+}
+
+func newKey(id string, method jwt.SigningMethod, signKey, verifyKey interface{}) *Key {
+	return &Key{
+		ID:        id,
+		method:    method,
+		signKey:   signKey,
+		verifyKey: verifyKey,
+		ja:        jwtauth.New(method.Alg(), nil, verifyKey),
+	}
+}
+
+// ParsePrivateKey parses PEM encoded RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8) private key.
+// If id is empty, RFC 7638 thumbprint of the public key is used
+func ParsePrivateKey(id string, data []byte) (*Key, error) {
+	const op = "lib.jwt.ParsePrivateKey"
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", op)
+	}
+
+	var (
+		priv interface{}
+		err  error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: %w: PEM block %q", op, ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		method jwt.SigningMethod
+		pub    interface{}
+	)
+
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%s: %w: RSA key must be at least %d bits", op, ErrUnsupportedKey, minRSAKeyBits)
+		}
+
+		method = jwt.SigningMethodRS256
+		pub = &k.PublicKey
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+		pub = k.Public().(ed25519.PublicKey)
+	default:
+		return nil, fmt.Errorf("%s: %w: %T", op, ErrUnsupportedKey, priv)
+	}
+
+	if id == "" {
+		jwKey, err := jwk.New(pub)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		thumbprint, err := jwKey.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		id = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	return newKey(id, method, priv, pub), nil
+}
+
+// LoadKey loads signing key from config. Private key file takes precedence over secret
+func LoadKey(cfg *config.Tokens) (*Key, error) {
+	const op = "lib.jwt.LoadKey"
+
+	if cfg.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		key, err := ParsePrivateKey(cfg.KeyID, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return key, nil
+	}
+
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoKey)
+	}
+
+	return NewHMACKey(cfg.KeyID, cfg.Secret), nil
+}
+
+// Alg returns JWS algorithm of the key
+func (k *Key) Alg() string {
+	return k.method.Alg()
+}
+
+// IsSymmetric reports whether key is a shared secret, which must never be published
+func (k *Key) IsSymmetric() bool {
+	_, ok := k.verifyKey.([]byte)
+	return ok
+}
+
+// PublicKeySet returns JWK set with public parts of the given keys. Symmetric keys are skipped
+func PublicKeySet(keys ...*Key) (jwk.Set, error) {
+	const op = "lib.jwt.PublicKeySet"
+
+	set := jwk.NewSet()
+
+	for _, k := range keys {
+		if k.IsSymmetric() {
+			continue
+		}
+
+		jwKey, err := jwk.New(k.verifyKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := jwKey.Set(jwk.KeyIDKey, k.ID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := jwKey.Set(jwk.AlgorithmKey, jwa.SignatureAlgorithm(k.Alg())); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := jwKey.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		set.Add(jwKey)
+	}
+
+	return set, nil
+}
+
+func (k *Key) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.ID != "" {
+		token.Header["kid"] = k.ID
+	}
+
+	return token.SignedString(k.signKey)
+}
@@ -0,0 +1,134 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePEM(t *testing.T, priv interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func newRSAKey(t *testing.T, id string) *jwt_lib.Key {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	key, err := jwt_lib.ParsePrivateKey(id, encodePEM(t, priv))
+	assert.NoError(t, err)
+
+	return key
+}
+
+func newEd25519Key(t *testing.T, id string) *jwt_lib.Key {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := jwt_lib.ParsePrivateKey(id, encodePEM(t, priv))
+	assert.NoError(t, err)
+
+	return key
+}
+
+func TestSignAndVerify(t *testing.T) {
+	keys := []*jwt_lib.Key{
+		jwt_lib.NewHMACKey("hmac", "secret"),
+		newRSAKey(t, "rsa"),
+		newEd25519Key(t, "ed"),
+	}
+
+	for _, key := range keys {
+		t.Run(key.Alg(), func(t *testing.T) {
+			tkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, time.Now().Add(time.Minute), key)
+			assert.NoError(t, err)
+
+			claims, err := jwt_lib.FromString(tkn, jwt_lib.NewKeySet(key))
+			assert.NoError(t, err)
+			assert.Equal(t, "user", claims["sub"])
+		})
+	}
+}
+
+func TestFromStringRejectsForeignTokens(t *testing.T) {
+	key := newRSAKey(t, "rsa")
+	exp := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		signer *jwt_lib.Key
+	}{
+		{
+			name:   "Another algorithm",
+			signer: jwt_lib.NewHMACKey("rsa", "secret"),
+		},
+		{
+			name:   "Another key",
+			signer: newRSAKey(t, "other"),
+		},
+		{
+			name:   "Same key id, another key",
+			signer: newRSAKey(t, "rsa"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, exp, tc.signer)
+			assert.NoError(t, err)
+
+			_, err = jwt_lib.FromString(tkn, jwt_lib.NewKeySet(key))
+			assert.ErrorIs(t, err, jwt_lib.ErrInvalid)
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	_, err = jwt_lib.ParsePrivateKey("", encodePEM(t, weak))
+	assert.ErrorIs(t, err, jwt_lib.ErrUnsupportedKey)
+
+	_, err = jwt_lib.ParsePrivateKey("", []byte("not a key"))
+	assert.Error(t, err)
+
+	key := newEd25519Key(t, "")
+	assert.Equal(t, "EdDSA", key.Alg())
+	assert.NotEmpty(t, key.ID, "kid defaults to thumbprint")
+}
+
+func TestPublicKeySet(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	edKey := newEd25519Key(t, "ed")
+
+	set, err := jwt_lib.PublicKeySet(rsaKey, jwt_lib.NewHMACKey("hmac", "secret"), edKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, set.Len())
+
+	_, ok := set.LookupKeyID("hmac")
+	assert.False(t, ok, "shared secret must not be published")
+
+	pub, ok := set.LookupKeyID("rsa")
+	assert.True(t, ok)
+	assert.Equal(t, "RS256", pub.Algorithm())
+	assert.Equal(t, string(jwk.ForSignature), pub.KeyUsage())
+
+	var raw rsa.PublicKey
+	assert.NoError(t, pub.Raw(&raw))
+
+	pub, ok = set.LookupKeyID("ed")
+	assert.True(t, ok)
+	assert.Equal(t, "EdDSA", pub.Algorithm())
+}
@@ -0,0 +1,299 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/random"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	activeFile    = "active"
+	privateKeyExt = ".pem"
+	secretExt     = ".secret"
+	hmacKeyIDLen  = 16
+)
+
+var ErrNoActiveKey = errors.New("active key not found")
+
+// Keyring is a directory of signing keys. Every "<kid>.pem" file holds RSA or Ed25519 private key
+// and every "<kid>.secret" file holds HS256 secret. File "active" contains kid of the signing key,
+// other keys are retired and only verify tokens issued before rotation.
+// Modification time of retired key file is its retirement time
+type Keyring struct {
+	dir string
+}
+
+func NewKeyring(dir string) *Keyring {
+	return &Keyring{
+		dir: dir,
+	}
+}
+
+// LoadKeySet loads key set from keyring if TOKENS_KEYS_DIR is set.
+// Otherwise set consists of the single key configured by secret or private key file
+func LoadKeySet(cfg *config.Tokens) (*KeySet, error) {
+	const op = "lib.jwt.LoadKeySet"
+
+	if cfg.KeysDir == "" {
+		key, err := LoadKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return NewKeySet(key), nil
+	}
+
+	active, retired, err := NewKeyring(cfg.KeysDir).Load()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return NewKeySet(active, retired...), nil
+}
+
+// Reload replaces keys of the set with keys loaded from config. On error the set is left unchanged
+func (ks *KeySet) Reload(cfg *config.Tokens) error {
+	const op = "lib.jwt.KeySet.Reload"
+
+	loaded, err := LoadKeySet(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := loaded.Keys()
+	ks.Replace(keys[0], keys[1:]...)
+
+	return nil
+}
+
+// Load returns active and retired keys of the keyring
+func (kr *Keyring) Load() (*Key, []*Key, error) {
+	const op = "lib.jwt.Keyring.Load"
+
+	activeID, err := kr.activeID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	files, err := kr.files()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		active  *Key
+		retired []*Key
+	)
+
+	for id, path := range files {
+		key, err := loadKeyFile(id, path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if id == activeID {
+			active = key
+			continue
+		}
+
+		retired = append(retired, key)
+	}
+
+	if active == nil {
+		return nil, nil, fmt.Errorf("%s: %w: %q", op, ErrNoActiveKey, activeID)
+	}
+
+	return active, retired, nil
+}
+
+// Rotate generates new key with given algorithm and makes it active. Previous active key is retired
+func (kr *Keyring) Rotate(alg string, now time.Time) (*Key, error) {
+	const op = "lib.jwt.Keyring.Rotate"
+
+	prevID, err := kr.activeID()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	files, err := kr.files()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, data, err := generateKey(alg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ext := privateKeyExt
+	if alg == jwt.SigningMethodHS256.Alg() {
+		ext = secretExt
+	}
+
+	path := filepath.Join(kr.dir, id+ext)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := loadKeyFile(id, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Rename is atomic, so reloading service never sees partially written file
+	tmp := filepath.Join(kr.dir, activeFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(id+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(kr.dir, activeFile)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if prevPath, ok := files[prevID]; ok {
+		if err := os.Chtimes(prevPath, now, now); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return key, nil
+}
+
+// Prune removes keys retired before now minus retention and returns their IDs.
+// Retention must be not less than lifetime of the longest-living token
+func (kr *Keyring) Prune(retention time.Duration, now time.Time) ([]string, error) {
+	const op = "lib.jwt.Keyring.Prune"
+
+	activeID, err := kr.activeID()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	files, err := kr.files()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var pruned []string
+
+	for id, path := range files {
+		if id == activeID {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return pruned, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if info.ModTime().Add(retention).After(now) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return pruned, fmt.Errorf("%s: %w", op, err)
+		}
+
+		pruned = append(pruned, id)
+	}
+
+	return pruned, nil
+}
+
+func (kr *Keyring) activeID() (string, error) {
+	data, err := os.ReadFile(filepath.Join(kr.dir, activeFile))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// files returns key file paths by kid
+func (kr *Keyring) files() (map[string]string, error) {
+	entries, err := os.ReadDir(kr.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != privateKeyExt && ext != secretExt) {
+			continue
+		}
+
+		files[strings.TrimSuffix(e.Name(), ext)] = filepath.Join(kr.dir, e.Name())
+	}
+
+	return files, nil
+}
+
+func loadKeyFile(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.Ext(path) == secretExt {
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return nil, fmt.Errorf("%w: empty secret %q", ErrUnsupportedKey, id)
+		}
+
+		return NewHMACKey(id, secret), nil
+	}
+
+	return ParsePrivateKey(id, data)
+}
+
+// generateKey returns kid and file contents of new key
+func generateKey(alg string) (string, []byte, error) {
+	var priv interface{}
+
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		return random.Token()[:hmacKeyIDLen], []byte(random.Token() + "\n"), nil
+	case jwt.SigningMethodRS256.Alg():
+		k, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+		if err != nil {
+			return "", nil, err
+		}
+		priv = k
+	case jwt.SigningMethodEdDSA.Alg():
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", nil, err
+		}
+		priv = k
+	default:
+		return "", nil, fmt.Errorf("%w: algorithm %q", ErrUnsupportedKey, alg)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	// kid of asymmetric key is its thumbprint, so the same key always gets the same ID
+	key, err := ParsePrivateKey("", data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return key.ID, data, nil
+}
@@ -0,0 +1,91 @@
+package jwt_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"e-commerce-users/internal/config"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyringRotate(t *testing.T) {
+	dir := t.TempDir()
+	kr := jwt_lib.NewKeyring(dir)
+	now := time.Now()
+
+	_, _, err := kr.Load()
+	assert.Error(t, err, "empty keyring has no active key")
+
+	first, err := kr.Rotate("HS256", now)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, first.ID+".secret"))
+
+	second, err := kr.Rotate("EdDSA", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "EdDSA", second.Alg())
+	assert.FileExists(t, filepath.Join(dir, second.ID+".pem"))
+
+	active, retired, err := kr.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, second.ID, active.ID)
+	assert.Len(t, retired, 1)
+	assert.Equal(t, first.ID, retired[0].ID)
+
+	_, err = kr.Rotate("none", now)
+	assert.ErrorIs(t, err, jwt_lib.ErrUnsupportedKey)
+}
+
+func TestKeyringPrune(t *testing.T) {
+	dir := t.TempDir()
+	kr := jwt_lib.NewKeyring(dir)
+	retiredAt := time.Now().Add(-time.Hour)
+
+	first, err := kr.Rotate("HS256", retiredAt)
+	assert.NoError(t, err)
+
+	second, err := kr.Rotate("HS256", retiredAt)
+	assert.NoError(t, err)
+
+	pruned, err := kr.Prune(2*time.Hour, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, pruned, "tokens of retired key may still be alive")
+
+	pruned, err = kr.Prune(30*time.Minute, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first.ID}, pruned)
+
+	active, retired, err := kr.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, second.ID, active.ID)
+	assert.Empty(t, retired)
+}
+
+func TestKeySetReload(t *testing.T) {
+	dir := t.TempDir()
+	kr := jwt_lib.NewKeyring(dir)
+	cfg := &config.Tokens{KeysDir: dir}
+
+	first, err := kr.Rotate("HS256", time.Now())
+	assert.NoError(t, err)
+
+	keys, err := jwt_lib.LoadKeySet(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, keys.Active().ID)
+
+	second, err := kr.Rotate("HS256", time.Now())
+	assert.NoError(t, err)
+
+	assert.NoError(t, keys.Reload(cfg))
+	assert.Equal(t, second.ID, keys.Active().ID)
+
+	_, ok := keys.Lookup(first.ID)
+	assert.True(t, ok, "retired key is kept for verification")
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "active")))
+	assert.Error(t, keys.Reload(cfg))
+	assert.Equal(t, second.ID, keys.Active().ID, "failed reload keeps previous keys")
+}
@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/jwtauth"
+	jwx_jwt "github.com/lestrrat-go/jwx/jwt"
+)
+
+// KeySet holds active signing key and retired keys, which are still accepted for verification
+// until tokens signed by them expire. Keys are selected by kid header. KeySet is safe for concurrent use
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeySet(active *Key, retired ...*Key) *KeySet {
+	ks := &KeySet{}
+	ks.Replace(active, retired...)
+
+	return ks
+}
+
+// Replace atomically swaps all keys of the set
+func (ks *KeySet) Replace(active *Key, retired ...*Key) {
+	keys := make(map[string]*Key, len(retired)+1)
+	for _, k := range retired {
+		keys[k.ID] = k
+	}
+	keys[active.ID] = active
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.active = active
+	ks.keys = keys
+}
+
+// Active returns key used to sign new tokens
+func (ks *KeySet) Active() *Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.active
+}
+
+// Lookup returns verification key with given kid. Tokens without kid
+// were issued before rotation was configured and are checked against active key
+func (ks *KeySet) Lookup(kid string) (*Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if kid == "" {
+		return ks.active, true
+	}
+
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+// Keys returns active key followed by retired keys ordered by ID
+func (ks *KeySet) Keys() []*Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if k != ks.active {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return append([]*Key{ks.active}, keys...)
+}
+
+// Verifier is jwtauth.Verifier counterpart, which selects verification key by kid header
+func (ks *KeySet) Verifier() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			token, err := ks.verifyRequest(r)
+			ctx = jwtauth.NewContext(ctx, token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (ks *KeySet) verifyRequest(r *http.Request) (jwx_jwt.Token, error) {
+	tokenString := jwtauth.TokenFromHeader(r)
+	if tokenString == "" {
+		tokenString = jwtauth.TokenFromCookie(r)
+	}
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+
+	key, ok := ks.Lookup(keyID(tokenString))
+	if !ok {
+		return nil, jwtauth.ErrUnauthorized
+	}
+
+	return jwtauth.VerifyToken(key.ja, tokenString)
+}
+
+// keyID returns kid header of token without verifying it. Malformed header yields empty kid
+func keyID(token string) string {
+	header, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return ""
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return ""
+	}
+
+	var h struct {
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return ""
+	}
+
+	return h.Kid
+}
@@ -0,0 +1,122 @@
+package jwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	exp := time.Now().Add(time.Minute)
+
+	oldKey := jwt_lib.NewHMACKey("old", "old-secret")
+	newKey := newEd25519Key(t, "new")
+
+	keys := jwt_lib.NewKeySet(oldKey)
+
+	oldTkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, exp, keys.Active())
+	assert.NoError(t, err)
+
+	keys.Replace(newKey, oldKey)
+	assert.Equal(t, "new", keys.Active().ID)
+
+	newTkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, exp, keys.Active())
+	assert.NoError(t, err)
+
+	_, err = jwt_lib.FromString(oldTkn, keys)
+	assert.NoError(t, err, "retired key still verifies")
+
+	_, err = jwt_lib.FromString(newTkn, keys)
+	assert.NoError(t, err)
+
+	keys.Replace(newKey)
+
+	_, err = jwt_lib.FromString(oldTkn, keys)
+	assert.ErrorIs(t, err, jwt_lib.ErrInvalid, "pruned key no longer verifies")
+}
+
+func TestKeySetTokenWithoutKid(t *testing.T) {
+	exp := time.Now().Add(time.Minute)
+
+	legacy := jwt_lib.NewHMACKey("", "secret")
+	tkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, exp, legacy)
+	assert.NoError(t, err)
+
+	_, err = jwt_lib.FromString(tkn, jwt_lib.NewKeySet(jwt_lib.NewHMACKey("v1", "secret")))
+	assert.NoError(t, err, "token without kid is checked against active key")
+
+	_, err = jwt_lib.FromString(tkn, jwt_lib.NewKeySet(jwt_lib.NewHMACKey("v2", "another"), jwt_lib.NewHMACKey("v1", "secret")))
+	assert.ErrorIs(t, err, jwt_lib.ErrInvalid)
+}
+
+func TestKeySetVerifier(t *testing.T) {
+	exp := time.Now().Add(time.Minute)
+
+	active := newRSAKey(t, "active")
+	retired := jwt_lib.NewHMACKey("retired", "secret")
+	keys := jwt_lib.NewKeySet(active, retired)
+
+	sign := func(key *jwt_lib.Key) string {
+		tkn, err := jwt_lib.NewAccessToken("user", "user", 1, "sid", false, nil, exp, key)
+		assert.NoError(t, err)
+		return tkn
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{
+			name:  "Active key",
+			token: sign(active),
+		},
+		{
+			name:  "Retired key",
+			token: sign(retired),
+		},
+		{
+			name:        "Unknown key",
+			token:       sign(jwt_lib.NewHMACKey("unknown", "secret")),
+			expectedErr: jwtauth.ErrUnauthorized,
+		},
+		{
+			name:        "No token",
+			expectedErr: jwtauth.ErrNoTokenFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				claims map[string]interface{}
+				err    error
+			)
+
+			h := keys.Verifier()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, claims, err = jwtauth.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "user", claims["sub"])
+		})
+	}
+}
@@ -0,0 +1,380 @@
+// Package oidc is OpenID Connect relying party signing users in with external identity providers
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"e-commerce-users/internal/config"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown provider")
+	// ErrRejected is returned when provider rejects the code or returns ID token which can't be trusted
+	ErrRejected = errors.New("rejected by provider")
+)
+
+// signingAlgs are accepted ID token algorithms. Symmetric ones would let anyone knowing
+// client secret forge tokens, "none" would let anyone at all
+var signingAlgs = []jwa.SignatureAlgorithm{jwa.RS256, jwa.PS256, jwa.ES256, jwa.EdDSA}
+
+const httpTimeout = 10 * time.Second
+
+// ProviderConfig is a single provider entry of providers file
+type ProviderConfig struct {
+	Name   string `json:"name"`
+	Issuer string `json:"issuer"`
+	// ClientSecret may reference environment variable as $NAME, so the file can be committed
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Identity is user identity asserted by ID token
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// State is kept between redirect to provider and the user coming back
+type State struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// UserID is set when signed in user links identity to own account
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Providers is registry of configured providers
+type Providers struct {
+	providers map[string]*Provider
+}
+
+// New loads providers listed in providers file. Without the file federated sign in is disabled
+func New(cfg *config.Federation) (*Providers, error) {
+	const op = "lib.oidc.New"
+
+	ps := &Providers{providers: map[string]*Provider{}}
+
+	if cfg.ProvidersFile == "" {
+		return ps, nil
+	}
+
+	data, err := os.ReadFile(cfg.ProvidersFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var cfgs []ProviderConfig
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, pc := range cfgs {
+		if pc.Name == "" || pc.Issuer == "" || pc.ClientID == "" || pc.RedirectURL == "" {
+			return nil, fmt.Errorf("%s: provider %q: name, issuer, client_id and redirect_url are required", op, pc.Name)
+		}
+
+		pc.ClientSecret = os.ExpandEnv(pc.ClientSecret)
+		ps.Add(NewProvider(pc, &http.Client{Timeout: httpTimeout}))
+	}
+
+	return ps, nil
+}
+
+func (ps *Providers) Add(p *Provider) {
+	ps.providers[p.cfg.Name] = p
+}
+
+func (ps *Providers) Get(name string) (*Provider, error) {
+	p, ok := ps.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return p, nil
+}
+
+// Provider is external OpenID Connect provider. Its metadata and keys are discovered on first use
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	meta     *metadata
+	keys     jwk.Set
+	keysTime time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+func NewProvider(cfg ProviderConfig, client *http.Client) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// AuthCodeURL returns provider URL user is sent to. State and nonce must be random,
+// verifier is PKCE code verifier kept until the user comes back
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	const op = "lib.oidc.AuthCodeURL"
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems authorization code and returns identity asserted by verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	const op = "lib.oidc.Exchange"
+
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %w: token endpoint returned %d: %s", op, ErrRejected, resp.StatusCode, body)
+	}
+
+	var tkn tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tkn); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity, err := p.verifyIDToken(ctx, meta, tkn.IDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, meta *metadata, idToken, nonce string) (*Identity, error) {
+	msg, err := jws.ParseString(idToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	headers := msg.Signatures()[0].ProtectedHeaders()
+
+	alg := headers.Algorithm()
+	if !slices.Contains(signingAlgs, alg) {
+		return nil, fmt.Errorf("%w: unexpected algorithm %s", ErrRejected, alg)
+	}
+
+	key, err := p.lookupKey(ctx, meta, headers.KeyID())
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	token, err := jwt.ParseString(idToken, jwt.WithVerify(alg, raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	if err := jwt.Validate(token, jwt.WithIssuer(meta.Issuer), jwt.WithAudience(p.cfg.ClientID)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	// Expiration is validated only if present, but ID token must have it
+	if token.Expiration().IsZero() || token.Subject() == "" {
+		return nil, fmt.Errorf("%w: exp and sub claims are required", ErrRejected)
+	}
+
+	claims := token.PrivateClaims()
+
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrRejected)
+	}
+
+	identity := &Identity{
+		Provider: p.cfg.Name,
+		Subject:  token.Subject(),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.GivenName, _ = claims["given_name"].(string)
+	identity.FamilyName, _ = claims["family_name"].(string)
+
+	// Some providers send email_verified as string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+
+	return identity, nil
+}
+
+// lookupKey finds ID token signing key. Key set is fetched again once in a while,
+// if the key is not found, because providers rotate keys
+func (p *Provider) lookupKey(ctx context.Context, meta *metadata, kid string) (jwk.Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	find := func() (jwk.Key, bool) {
+		if p.keys == nil {
+			return nil, false
+		}
+		if kid == "" {
+			if p.keys.Len() != 1 {
+				return nil, false
+			}
+			return p.keys.Get(0)
+		}
+		return p.keys.LookupKeyID(kid)
+	}
+
+	if key, ok := find(); ok {
+		return key, nil
+	}
+
+	if time.Since(p.keysTime) < time.Minute {
+		return nil, fmt.Errorf("%w: signing key %q not found", ErrRejected, kid)
+	}
+
+	keys, err := jwk.Fetch(ctx, meta.JWKSURI, jwk.WithHTTPClient(p.client))
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.keysTime = time.Now()
+
+	if key, ok := find(); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: signing key %q not found", ErrRejected, kid)
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned %d", resp.StatusCode)
+	}
+
+	var meta metadata
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, err
+	}
+
+	// Issuer mix-up would let one provider assert identities of another
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovered issuer %q doesn't match configured %q", meta.Issuer, p.cfg.Issuer)
+	}
+
+	p.meta = &meta
+
+	return p.meta, nil
+}
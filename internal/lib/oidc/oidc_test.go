@@ -0,0 +1,266 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/oidc"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubProvider is in-process identity provider returning ID token built by the test
+type stubProvider struct {
+	srv     *httptest.Server
+	issuer  string
+	key     jwk.Key
+	idToken func(t *testing.T) string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := jwk.New(priv)
+	assert.NoError(t, err)
+	assert.NoError(t, key.Set(jwk.KeyIDKey, "idp-key"))
+
+	sp := &stubProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
+			"issuer":                 sp.issuer,
+			"authorization_endpoint": sp.srv.URL + "/authorize",
+			"token_endpoint":         sp.srv.URL + "/token",
+			"jwks_uri":               sp.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub, err := jwk.PublicKeyOf(sp.key)
+		assert.NoError(t, err)
+
+		set := jwk.NewSet()
+		set.Add(pub)
+		json.NewEncoder(w).Encode(set) //nolint:errcheck
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "code" || r.PostFormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`)) //nolint:errcheck
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": sp.idToken(t)}) //nolint:errcheck
+	})
+
+	sp.srv = httptest.NewServer(mux)
+	sp.issuer = sp.srv.URL
+	t.Cleanup(sp.srv.Close)
+
+	return sp
+}
+
+func (sp *stubProvider) provider() *oidc.Provider {
+	return oidc.NewProvider(oidc.ProviderConfig{
+		Name:        "stub",
+		Issuer:      sp.srv.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost:8080/callback",
+	}, sp.srv.Client())
+}
+
+func sign(t *testing.T, claims map[string]interface{}, alg jwa.SignatureAlgorithm, key interface{}) string {
+	tkn := jwt.New()
+	for k, v := range claims {
+		assert.NoError(t, tkn.Set(k, v))
+	}
+
+	signed, err := jwt.Sign(tkn, alg, key)
+	assert.NoError(t, err)
+
+	return string(signed)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	sp := newStubProvider(t)
+
+	raw, err := sp.provider().AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.NoError(t, err)
+
+	u, err := url.Parse(raw)
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256([]byte("verifier"))
+
+	q := u.Query()
+	assert.Equal(t, sp.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "client", q.Get("client_id"))
+	assert.Equal(t, "openid email profile", q.Get("scope"))
+	assert.Equal(t, "nonce", q.Get("nonce"))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+}
+
+func TestAuthCodeURLIssuerMismatch(t *testing.T) {
+	sp := newStubProvider(t)
+	sp.issuer = "https://evil.example.com"
+
+	_, err := sp.provider().AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.Error(t, err)
+}
+
+func TestExchange(t *testing.T) {
+	sp := newStubProvider(t)
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":            sp.srv.URL,
+			"aud":            "client",
+			"sub":            "42",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          "nonce",
+			"email":          "jhon@mail.com",
+			"email_verified": true,
+			"given_name":     "Jhon",
+			"family_name":    "Doe",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	foreign, err := jwk.New([]byte("client-secret"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		idToken  func(t *testing.T) string
+		expected *oidc.Identity
+	}{
+		{
+			name: "Valid ID token",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(nil), jwa.EdDSA, sp.key)
+			},
+			expected: &oidc.Identity{
+				Provider:      "stub",
+				Subject:       "42",
+				Email:         "jhon@mail.com",
+				EmailVerified: true,
+				GivenName:     "Jhon",
+				FamilyName:    "Doe",
+			},
+		},
+		{
+			name: "Email verified as string",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"email_verified": "false"}), jwa.EdDSA, sp.key)
+			},
+			expected: &oidc.Identity{
+				Provider:   "stub",
+				Subject:    "42",
+				Email:      "jhon@mail.com",
+				GivenName:  "Jhon",
+				FamilyName: "Doe",
+			},
+		},
+		{
+			name: "Code rejected",
+			code: "bad-code",
+		},
+		{
+			name: "Nonce mismatch",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"nonce": "replayed"}), jwa.EdDSA, sp.key)
+			},
+		},
+		{
+			name: "Another audience",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"aud": "other-client"}), jwa.EdDSA, sp.key)
+			},
+		},
+		{
+			name: "Another issuer",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"iss": "https://evil.example.com"}), jwa.EdDSA, sp.key)
+			},
+		},
+		{
+			name: "Expired",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}), jwa.EdDSA, sp.key)
+			},
+		},
+		{
+			name: "Without expiration",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(map[string]interface{}{"exp": nil}), jwa.EdDSA, sp.key)
+			},
+		},
+		{
+			name: "Signed with client secret",
+			code: "code",
+			idToken: func(t *testing.T) string {
+				return sign(t, claims(nil), jwa.HS256, foreign)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sp.idToken = tc.idToken
+
+			identity, err := sp.provider().Exchange(context.Background(), tc.code, "verifier", "nonce")
+			if tc.expected == nil {
+				assert.ErrorIs(t, err, oidc.ErrRejected)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, identity)
+		})
+	}
+}
+
+func TestProvidersGet(t *testing.T) {
+	sp := newStubProvider(t)
+
+	ps, err := oidc.New(&config.Federation{})
+	assert.NoError(t, err)
+
+	_, err = ps.Get("stub")
+	assert.ErrorIs(t, err, oidc.ErrUnknownProvider)
+
+	ps.Add(sp.provider())
+
+	_, err = ps.Get("stub")
+	assert.NoError(t, err)
+}
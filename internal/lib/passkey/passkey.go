@@ -0,0 +1,154 @@
+// Package passkey adapts users and their passkeys to WebAuthn relying party
+package passkey
+
+import (
+	"bytes"
+	"strings"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/models"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// New creates WebAuthn relying party which registers discoverable credentials, so users can sign in without email
+func New(cfg *config.WebAuthn) (*webauthn.WebAuthn, error) {
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    cfg.CeremonyTTL,
+		TimeoutUVD: cfg.CeremonyTTL,
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+}
+
+// User implements webauthn.User. User ID is used as WebAuthn user handle
+type User struct {
+	user     *models.User
+	passkeys []models.Passkey
+}
+
+func NewUser(user *models.User, passkeys []models.Passkey) *User {
+	return &User{
+		user:     user,
+		passkeys: passkeys,
+	}
+}
+
+func (u *User) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *User) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *User) WebAuthnDisplayName() string {
+	name := strings.TrimSpace(u.user.Name + " " + u.user.Surname)
+	if name == "" {
+		return u.user.Email
+	}
+
+	return name
+}
+
+func (u *User) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *User) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, p := range u.passkeys {
+		creds = append(creds, credential(p))
+	}
+
+	return creds
+}
+
+// Exclusions returns descriptors of already registered passkeys, so authenticator does not register them twice
+func (u *User) Exclusions() []protocol.CredentialDescriptor {
+	descriptors := make([]protocol.CredentialDescriptor, 0, len(u.passkeys))
+	for _, p := range u.passkeys {
+		descriptors = append(descriptors, credential(p).Descriptor())
+	}
+
+	return descriptors
+}
+
+// Passkey returns stored passkey with given credential ID
+func (u *User) Passkey(id []byte) (*models.Passkey, bool) {
+	for i := range u.passkeys {
+		if bytes.Equal(u.passkeys[i].ID, id) {
+			return &u.passkeys[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// NewPasskey creates passkey model from credential verified during registration ceremony
+func NewPasskey(userID, name string, cred *webauthn.Credential) *models.Passkey {
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+
+	return &models.Passkey{
+		ID:              cred.ID,
+		UserID:          userID,
+		Name:            name,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      transports,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+}
+
+func credential(p models.Passkey) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, 0, len(p.Transports))
+	for _, t := range p.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(t))
+	}
+
+	return webauthn.Credential{
+		ID:              p.ID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: p.BackupEligible,
+			BackupState:    p.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+// ParseCreation parses response of authenticator to registration challenge
+func ParseCreation(data []byte) (*protocol.ParsedCredentialCreationData, error) {
+	return protocol.ParseCredentialCreationResponseBody(bytes.NewReader(data))
+}
+
+// ParseAssertion parses response of authenticator to login challenge
+func ParseAssertion(data []byte) (*protocol.ParsedCredentialAssertionData, error) {
+	return protocol.ParseCredentialRequestResponseBody(bytes.NewReader(data))
+}
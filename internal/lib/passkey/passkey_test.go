@@ -0,0 +1,247 @@
+package passkey_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/passkey"
+	"e-commerce-users/internal/models"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// softAuthenticator is a platform authenticator emulated in software.
+// It creates ES256 discoverable credentials with "none" attestation
+type softAuthenticator struct {
+	rpID       string
+	origin     string
+	key        *ecdsa.PrivateKey
+	credID     []byte
+	userHandle []byte
+	counter    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, rpID, origin string) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	credID := make([]byte, 16)
+	_, err = rand.Read(credID)
+	assert.NoError(t, err)
+
+	return &softAuthenticator{
+		rpID:   rpID,
+		origin: origin,
+		key:    key,
+		credID: credID,
+	}
+}
+
+// create answers registration challenge like navigator.credentials.create()
+func (a *softAuthenticator) create(t *testing.T, options *protocol.CredentialCreation) []byte {
+	a.userHandle = options.Response.User.ID.(protocol.URLEncodedBase64)
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	assert.NoError(t, err)
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credID)))
+	authData = append(authData, a.credID...)
+	authData = append(authData, coseKey...)
+
+	attObj, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	assert.NoError(t, err)
+
+	return a.respond(t, map[string]string{
+		"clientDataJSON":    encode(a.clientData(t, "webauthn.create", options.Response.Challenge)),
+		"attestationObject": encode(attObj),
+	})
+}
+
+// get answers login challenge like navigator.credentials.get()
+func (a *softAuthenticator) get(t *testing.T, options *protocol.CredentialAssertion) []byte {
+	a.counter++
+
+	clientData := a.clientData(t, "webauthn.get", options.Response.Challenge)
+	authData := a.authData(flagUserPresent | flagUserVerified)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	assert.NoError(t, err)
+
+	return a.respond(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(sig),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+	assert.NoError(t, err)
+
+	return data
+}
+
+func (a *softAuthenticator) respond(t *testing.T, response map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       encode(a.credID),
+		"rawId":    encode(a.credID),
+		"type":     "public-key",
+		"response": response,
+	})
+	assert.NoError(t, err)
+
+	return data
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newRelyingParty(t *testing.T) *webauthn.WebAuthn {
+	wa, err := passkey.New(&config.WebAuthn{
+		RPID:          "localhost",
+		RPDisplayName: "e-commerce",
+		RPOrigins:     []string{"http://localhost:8080"},
+		CeremonyTTL:   time.Minute,
+	})
+	assert.NoError(t, err)
+
+	return wa
+}
+
+// register runs registration ceremony and returns stored passkey
+func register(t *testing.T, wa *webauthn.WebAuthn, auth *softAuthenticator, user *models.User) *models.Passkey {
+	creation, session, err := wa.BeginRegistration(passkey.NewUser(user, nil))
+	assert.NoError(t, err)
+
+	parsed, err := passkey.ParseCreation(auth.create(t, creation))
+	assert.NoError(t, err)
+	assert.Equal(t, session.Challenge, parsed.Response.CollectedClientData.Challenge)
+
+	cred, err := wa.CreateCredential(passkey.NewUser(user, nil), *session, parsed)
+	assert.NoError(t, err)
+
+	return passkey.NewPasskey(user.ID, "Laptop", cred)
+}
+
+func TestCeremonies(t *testing.T) {
+	wa := newRelyingParty(t)
+	auth := newSoftAuthenticator(t, "localhost", "http://localhost:8080")
+
+	user := &models.User{
+		ID:    "3f78ac72-37c1-47ee-9747-bb06214f5310",
+		Name:  "Jhon",
+		Email: "jhon@mail.com",
+	}
+
+	pk := register(t, wa, auth, user)
+	assert.Equal(t, auth.credID, pk.ID)
+	assert.Equal(t, user.ID, pk.UserID)
+	assert.Equal(t, "none", pk.AttestationType)
+
+	passkeys := []models.Passkey{*pk}
+
+	assertion, session, err := wa.BeginDiscoverableLogin()
+	assert.NoError(t, err)
+
+	parsed, err := passkey.ParseAssertion(auth.get(t, assertion))
+	assert.NoError(t, err)
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		assert.Equal(t, user.ID, string(userHandle))
+		return passkey.NewUser(user, passkeys), nil
+	}
+
+	cred, err := wa.ValidateDiscoverableLogin(handler, *session, parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, pk.ID, cred.ID)
+	assert.Equal(t, uint32(1), cred.Authenticator.SignCount)
+	assert.True(t, cred.Flags.UserVerified)
+	assert.False(t, cred.Authenticator.CloneWarning)
+
+	stored, ok := passkey.NewUser(user, passkeys).Passkey(cred.ID)
+	assert.True(t, ok)
+	assert.Equal(t, "Laptop", stored.Name)
+}
+
+func TestLoginFromForeignOrigin(t *testing.T) {
+	wa := newRelyingParty(t)
+	auth := newSoftAuthenticator(t, "localhost", "http://localhost:8080")
+
+	user := &models.User{ID: "3f78ac72-37c1-47ee-9747-bb06214f5310", Email: "jhon@mail.com"}
+	passkeys := []models.Passkey{*register(t, wa, auth, user)}
+
+	assertion, session, err := wa.BeginDiscoverableLogin()
+	assert.NoError(t, err)
+
+	auth.origin = "https://evil.example"
+
+	parsed, err := passkey.ParseAssertion(auth.get(t, assertion))
+	assert.NoError(t, err)
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		return passkey.NewUser(user, passkeys), nil
+	}
+
+	_, err = wa.ValidateDiscoverableLogin(handler, *session, parsed)
+	assert.Error(t, err)
+}
+
+func TestExclusions(t *testing.T) {
+	wa := newRelyingParty(t)
+	auth := newSoftAuthenticator(t, "localhost", "http://localhost:8080")
+
+	user := &models.User{ID: "3f78ac72-37c1-47ee-9747-bb06214f5310", Email: "jhon@mail.com"}
+	pk := register(t, wa, auth, user)
+
+	exclusions := passkey.NewUser(user, []models.Passkey{*pk}).Exclusions()
+	assert.Len(t, exclusions, 1)
+	assert.Equal(t, protocol.URLEncodedBase64(pk.ID), exclusions[0].CredentialID)
+}
@@ -0,0 +1,188 @@
+// Package password hashes passwords into PHC strings tagged with algorithm and parameters,
+// so hashes of different algorithms and parameters can be verified side by side
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"e-commerce-users/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgArgon2id = "argon2id"
+	AlgBcrypt   = "bcrypt"
+)
+
+const (
+	saltLen = 16
+	keyLen  = 32
+	// bcryptMaxLen is the longest password in bytes bcrypt hashes
+	bcryptMaxLen = 72
+)
+
+var (
+	ErrMismatch    = errors.New("password mismatch")
+	ErrUnknownHash = errors.New("unknown hash format")
+)
+
+var encoding = base64.RawStdEncoding
+
+type argon2Params struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	keyLen      uint32
+}
+
+// Hasher hashes new passwords with configured algorithm and verifies hashes of any supported one
+type Hasher struct {
+	alg        string
+	argon2     argon2Params
+	bcryptCost int
+}
+
+func New(cfg *config.Password) (*Hasher, error) {
+	const op = "lib.password.New"
+
+	switch cfg.Algorithm {
+	case AlgArgon2id:
+		if cfg.Argon2Memory == 0 || cfg.Argon2Time == 0 || cfg.Argon2Parallelism == 0 {
+			return nil, fmt.Errorf("%s: argon2id memory, time and parallelism must be positive", op)
+		}
+	case AlgBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%s: bcrypt cost must be between %d and %d", op, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		if cfg.MaxLength <= 0 || cfg.MaxLength > bcryptMaxLen {
+			return nil, fmt.Errorf("%s: max password length must be between 1 and %d with bcrypt", op, bcryptMaxLen)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported algorithm %q", op, cfg.Algorithm)
+	}
+
+	return &Hasher{
+		alg: cfg.Algorithm,
+		argon2: argon2Params{
+			memory:      cfg.Argon2Memory,
+			time:        cfg.Argon2Time,
+			parallelism: cfg.Argon2Parallelism,
+			keyLen:      keyLen,
+		},
+		bcryptCost: cfg.BcryptCost,
+	}, nil
+}
+
+// Hash returns PHC string of password hashed with configured algorithm
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	const op = "lib.password.Hash"
+
+	if h.alg == AlgBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return hash, nil
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, p.keyLen)
+
+	return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgArgon2id, argon2.Version, p.memory, p.time, p.parallelism,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key),
+	)), nil
+}
+
+// Verify compares password with hash and returns ErrMismatch if they don't match.
+// Rehash reports the hash is made with another algorithm or outdated parameters,
+// so it should be replaced with a new hash of the same password
+func (h *Hasher) Verify(hash []byte, password string) (bool, error) {
+	const op = "lib.password.Verify"
+
+	switch {
+	case strings.HasPrefix(string(hash), "$"+AlgArgon2id+"$"):
+		p, salt, key, err := parseArgon2id(string(hash))
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.parallelism, p.keyLen)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, fmt.Errorf("%s: %w", op, ErrMismatch)
+		}
+
+		return h.alg != AlgArgon2id || p != h.argon2, nil
+
+	case isBcrypt(hash):
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, fmt.Errorf("%s: %w", op, ErrMismatch)
+			}
+
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		cost, err := bcrypt.Cost(hash)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return h.alg != AlgBcrypt || cost != h.bcryptCost, nil
+	}
+
+	return false, fmt.Errorf("%s: %w", op, ErrUnknownHash)
+}
+
+// isBcrypt reports hash is in modular crypt format of bcrypt, which hashes made before
+// argon2id support are stored in
+func isBcrypt(hash []byte) bool {
+	s := string(hash)
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// parseArgon2id parses $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func parseArgon2id(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	p.keyLen = uint32(len(key)) //nolint:gosec // key length is a few dozen bytes
+
+	return p, salt, key, nil
+}
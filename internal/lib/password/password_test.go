@@ -0,0 +1,175 @@
+package password_test
+
+import (
+	"regexp"
+	"testing"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/password"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	argon2Cfg = config.Password{Algorithm: password.AlgArgon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Parallelism: 1, BcryptCost: bcrypt.MinCost}
+	bcryptCfg = config.Password{Algorithm: password.AlgBcrypt, Argon2Memory: 64, Argon2Time: 1, Argon2Parallelism: 1, BcryptCost: bcrypt.MinCost, MaxLength: 72}
+)
+
+func mustNew(t *testing.T, cfg config.Password) *password.Hasher {
+	h, err := password.New(&cfg)
+	assert.NoError(t, err)
+
+	return h
+}
+
+func TestHash(t *testing.T) {
+	h := mustNew(t, argon2Cfg)
+
+	hash, err := h.Hash("qwerty")
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`), string(hash))
+
+	other, err := h.Hash("qwerty")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salt must be random")
+
+	h = mustNew(t, bcryptCfg)
+
+	hash, err = h.Hash("qwerty")
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\$2a\$04\$`), string(hash))
+}
+
+func TestVerify(t *testing.T) {
+	argon2Hash, err := mustNew(t, argon2Cfg).Hash("qwerty")
+	assert.NoError(t, err)
+
+	weakCfg := argon2Cfg
+	weakCfg.Argon2Memory = 32
+	weakHash, err := mustNew(t, weakCfg).Hash("qwerty")
+	assert.NoError(t, err)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("qwerty"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	costlyHash, err := bcrypt.GenerateFromPassword([]byte("qwerty"), bcrypt.MinCost+1)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		cfg            config.Password
+		hash           []byte
+		password       string
+		expectedRehash bool
+		expectedErr    error
+	}{
+		{
+			name:     "Argon2id",
+			cfg:      argon2Cfg,
+			hash:     argon2Hash,
+			password: "qwerty",
+		},
+		{
+			name:        "Argon2id mismatch",
+			cfg:         argon2Cfg,
+			hash:        argon2Hash,
+			password:    "qwertz",
+			expectedErr: password.ErrMismatch,
+		},
+		{
+			name:           "Argon2id with outdated parameters",
+			cfg:            argon2Cfg,
+			hash:           weakHash,
+			password:       "qwerty",
+			expectedRehash: true,
+		},
+		{
+			name:           "Bcrypt when argon2id is configured",
+			cfg:            argon2Cfg,
+			hash:           bcryptHash,
+			password:       "qwerty",
+			expectedRehash: true,
+		},
+		{
+			name:        "Bcrypt mismatch",
+			cfg:         argon2Cfg,
+			hash:        bcryptHash,
+			password:    "qwertz",
+			expectedErr: password.ErrMismatch,
+		},
+		{
+			name:     "Bcrypt",
+			cfg:      bcryptCfg,
+			hash:     bcryptHash,
+			password: "qwerty",
+		},
+		{
+			name:           "Bcrypt with another cost",
+			cfg:            bcryptCfg,
+			hash:           costlyHash,
+			password:       "qwerty",
+			expectedRehash: true,
+		},
+		{
+			name:           "Argon2id when bcrypt is configured",
+			cfg:            bcryptCfg,
+			hash:           argon2Hash,
+			password:       "qwerty",
+			expectedRehash: true,
+		},
+		{
+			name:        "Unknown algorithm",
+			cfg:         argon2Cfg,
+			hash:        []byte("$scrypt$ln=15,r=8,p=1$c2FsdA$aGFzaA"),
+			password:    "qwerty",
+			expectedErr: password.ErrUnknownHash,
+		},
+		{
+			name:        "Malformed argon2id",
+			cfg:         argon2Cfg,
+			hash:        []byte("$argon2id$v=19$m=64,t=1$c2FsdA$aGFzaA"),
+			password:    "qwerty",
+			expectedErr: password.ErrUnknownHash,
+		},
+		{
+			name:        "Another argon2 version",
+			cfg:         argon2Cfg,
+			hash:        []byte("$argon2id$v=16$m=64,t=1,p=1$c2FsdA$aGFzaA"),
+			password:    "qwerty",
+			expectedErr: password.ErrUnknownHash,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rehash, err := mustNew(t, tc.cfg).Verify(tc.hash, tc.password)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedRehash, rehash)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Password
+	}{
+		{name: "Unknown algorithm", cfg: config.Password{Algorithm: "md5"}},
+		{name: "Zero argon2id memory", cfg: config.Password{Algorithm: password.AlgArgon2id, Argon2Time: 1, Argon2Parallelism: 1}},
+		{name: "Too low bcrypt cost", cfg: config.Password{Algorithm: password.AlgBcrypt, BcryptCost: 1, MaxLength: 72}},
+		{name: "Too long passwords for bcrypt", cfg: config.Password{Algorithm: password.AlgBcrypt, BcryptCost: 10, MaxLength: 128}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := password.New(&tc.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
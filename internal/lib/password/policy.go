@@ -0,0 +1,118 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"e-commerce-users/internal/config"
+)
+
+// personalMinLen is the shortest personal info part checked, shorter ones match too many passwords
+const personalMinLen = 3
+
+//go:embed common.txt
+var commonList string
+
+// common is set of lowercased common passwords
+var common = func() map[string]struct{} {
+	set := make(map[string]struct{})
+
+	sc := bufio.NewScanner(strings.NewReader(commonList))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			set[strings.ToLower(line)] = struct{}{}
+		}
+	}
+
+	return set
+}()
+
+// Policy checks passwords against configured rules
+type Policy struct {
+	minLen     int
+	maxLen     int
+	minClasses int
+}
+
+func NewPolicy(cfg *config.Password) *Policy {
+	return &Policy{
+		minLen:     cfg.MinLength,
+		maxLen:     cfg.MaxLength,
+		minClasses: cfg.MinClasses,
+	}
+}
+
+// Check returns rules violated by password, named like validation tags. Personal is user info,
+// such as email and name, password must not contain
+func (p *Policy) Check(password string, personal ...string) []string {
+	var violated []string
+
+	if p.minLen > 0 && utf8.RuneCountInString(password) < p.minLen {
+		violated = append(violated, fmt.Sprintf("min=%d", p.minLen))
+	}
+
+	// Limit is in bytes, because hashing cost grows with them
+	if p.maxLen > 0 && len(password) > p.maxLen {
+		violated = append(violated, fmt.Sprintf("max=%d", p.maxLen))
+	}
+
+	if p.minClasses > 0 && classes(password) < p.minClasses {
+		violated = append(violated, fmt.Sprintf("classes=%d", p.minClasses))
+	}
+
+	if containsPersonal(password, personal) {
+		violated = append(violated, "not_personal")
+	}
+
+	if _, ok := common[strings.ToLower(password)]; ok {
+		violated = append(violated, "not_common")
+	}
+
+	return violated
+}
+
+// classes counts character classes of password: lowercase and uppercase letters, digits and others
+func classes(password string) int {
+	var lower, upper, digit, other int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+
+	return lower + upper + digit + other
+}
+
+// containsPersonal reports whether password contains any personal info or local part of email
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+
+	for _, info := range personal {
+		info = strings.ToLower(strings.TrimSpace(info))
+
+		parts := []string{info}
+		if local, _, ok := strings.Cut(info, "@"); ok {
+			parts = append(parts, local)
+		}
+
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= personalMinLen && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
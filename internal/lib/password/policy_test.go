@@ -0,0 +1,87 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/password"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := password.NewPolicy(&config.Password{MinLength: 8, MaxLength: 72, MinClasses: 3})
+
+	tests := []struct {
+		name     string
+		password string
+		personal []string
+		expected []string
+	}{
+		{
+			name:     "Strong password",
+			password: "Tr0ub4dor&3",
+			personal: []string{"jhon@mail.com", "Jhon", "Doe"},
+		},
+		{
+			name:     "Too short",
+			password: "1",
+			expected: []string{"min=8", "classes=3"},
+		},
+		{
+			name:     "Length in characters",
+			password: "Пароль1!",
+		},
+		{
+			name:     "Too long",
+			password: "Aa1" + strings.Repeat("x", 70),
+			expected: []string{"max=72"},
+		},
+		{
+			name:     "Too few character classes",
+			password: "correcthorsebatterystaple",
+			expected: []string{"classes=3"},
+		},
+		{
+			name:     "Contains name",
+			password: "JHONsecure1",
+			personal: []string{"jhon@mail.com", "Jhon", "Doe"},
+			expected: []string{"not_personal"},
+		},
+		{
+			name:     "Contains email local part",
+			password: "x-Jhon.Doe-1",
+			personal: []string{"jhon.doe@mail.com"},
+			expected: []string{"not_personal"},
+		},
+		{
+			name:     "Short personal info is ignored",
+			password: "Bo-secure-1",
+			personal: []string{"bo@mail.com", "Bo"},
+		},
+		{
+			name:     "Common password",
+			password: "Password123!",
+			expected: []string{"not_common"},
+		},
+		{
+			name:     "Common password in another case",
+			password: "P@SSW0RD",
+			expected: []string{"not_common"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, policy.Check(tc.password, tc.personal...))
+		})
+	}
+}
+
+func TestPolicyDisabledRules(t *testing.T) {
+	policy := password.NewPolicy(&config.Password{})
+
+	assert.Empty(t, policy.Check("1"))
+	assert.Equal(t, []string{"not_common"}, policy.Check("qwerty"))
+}
@@ -0,0 +1,149 @@
+package password
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"e-commerce-users/internal/config"
+)
+
+var ErrBusy = errors.New("hashing pool is busy")
+
+// Backend hashes and verifies passwords on caller goroutine, Hasher is the one used by the service
+type Backend interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (bool, error)
+}
+
+// Pool bounds CPU spent on password hashing. At most Workers hashes run at once and at most
+// QueueSize callers wait for a free worker, each no longer than QueueTimeout. The rest get
+// ErrBusy at once, so a burst of sign ins fails fast instead of starving other requests
+type Pool struct {
+	backend Backend
+	// workers holds a token per running hash, admitted per running or waiting one
+	workers  chan struct{}
+	admitted chan struct{}
+	timeout  time.Duration
+
+	completed atomic.Int64
+	rejected  atomic.Int64
+	timeouts  atomic.Int64
+	waitNanos atomic.Int64
+	hashNanos atomic.Int64
+}
+
+// NewPool runs backend on configured number of workers. Without it workers take half of CPUs,
+// counting every argon2id hash as Argon2Parallelism threads
+func NewPool(backend Backend, cfg *config.Password) *Pool {
+	workers := cfg.Workers
+	if workers <= 0 {
+		threads := 1
+		if cfg.Algorithm == AlgArgon2id && cfg.Argon2Parallelism > 0 {
+			threads = int(cfg.Argon2Parallelism)
+		}
+
+		workers = max(runtime.GOMAXPROCS(0)/2/threads, 1)
+	}
+
+	return &Pool{
+		backend:  backend,
+		workers:  make(chan struct{}, workers),
+		admitted: make(chan struct{}, workers+max(cfg.QueueSize, 0)),
+		timeout:  cfg.QueueTimeout,
+	}
+}
+
+// Hash hashes password on a pool worker
+func (p *Pool) Hash(password string) ([]byte, error) {
+	var hash []byte
+
+	err := p.run(func() error {
+		var err error
+		hash, err = p.backend.Hash(password)
+		return err
+	})
+
+	return hash, err
+}
+
+// Verify verifies password on a pool worker
+func (p *Pool) Verify(hash []byte, password string) (bool, error) {
+	var rehash bool
+
+	err := p.run(func() error {
+		var err error
+		rehash, err = p.backend.Verify(hash, password)
+		return err
+	})
+
+	return rehash, err
+}
+
+// run waits for a free worker and calls fn while holding it
+func (p *Pool) run(fn func() error) error {
+	const op = "lib.password.Pool"
+
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		p.rejected.Add(1)
+		return fmt.Errorf("%s: queue is full: %w", op, ErrBusy)
+	}
+
+	defer func() { <-p.admitted }()
+
+	// Nil channel never fires, so zero timeout waits as long as it takes
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+
+	select {
+	case p.workers <- struct{}{}:
+	case <-timeout:
+		p.timeouts.Add(1)
+		return fmt.Errorf("%s: queue timeout: %w", op, ErrBusy)
+	}
+
+	defer func() { <-p.workers }()
+
+	p.waitNanos.Add(int64(time.Since(start)))
+	start = time.Now()
+
+	err := fn()
+
+	p.hashNanos.Add(int64(time.Since(start)))
+	p.completed.Add(1)
+
+	return err
+}
+
+// Metrics returns pool state to publish with expvar. Totals of wait and hash seconds
+// divided by completed give average latencies
+func (p *Pool) Metrics() expvar.Var {
+	m := new(expvar.Map)
+
+	m.Set("workers", intFunc(func() int64 { return int64(cap(p.workers)) }))
+	m.Set("queue_size", intFunc(func() int64 { return int64(cap(p.admitted) - cap(p.workers)) }))
+	m.Set("active", intFunc(func() int64 { return int64(len(p.workers)) }))
+	m.Set("queued", intFunc(func() int64 { return int64(max(len(p.admitted)-len(p.workers), 0)) }))
+	m.Set("completed", intFunc(p.completed.Load))
+	m.Set("rejected", intFunc(p.rejected.Load))
+	m.Set("timeouts", intFunc(p.timeouts.Load))
+	m.Set("wait_seconds_total", expvar.Func(func() any { return time.Duration(p.waitNanos.Load()).Seconds() }))
+	m.Set("hash_seconds_total", expvar.Func(func() any { return time.Duration(p.hashNanos.Load()).Seconds() }))
+
+	return m
+}
+
+func intFunc(f func() int64) expvar.Func {
+	return func() any { return f() }
+}
@@ -0,0 +1,261 @@
+package password_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/password"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBackend hashes only after release is closed, reporting every started hash to started
+type blockingBackend struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingBackend() *blockingBackend {
+	return &blockingBackend{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blockingBackend) Hash(string) ([]byte, error) {
+	b.started <- struct{}{}
+	<-b.release
+
+	return []byte("hash"), nil
+}
+
+func (b *blockingBackend) Verify([]byte, string) (bool, error) {
+	_, err := b.Hash("")
+	return false, err
+}
+
+func metric(t *testing.T, pool *password.Pool, name string) string {
+	t.Helper()
+
+	m, ok := pool.Metrics().(*expvar.Map)
+	require.True(t, ok)
+
+	return m.Get(name).String()
+}
+
+func TestPoolQueueIsFull(t *testing.T) {
+	backend := newBlockingBackend()
+	pool := password.NewPool(backend, &config.Password{Workers: 1, QueueSize: 1})
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := pool.Hash("qwerty")
+			assert.NoError(t, err)
+		}()
+	}
+
+	<-backend.started
+	assert.Eventually(t, func() bool { return metric(t, pool, "queued") == "1" }, time.Second, time.Millisecond)
+	assert.Equal(t, "1", metric(t, pool, "active"))
+
+	_, err := pool.Verify([]byte("hash"), "qwerty")
+	assert.ErrorIs(t, err, password.ErrBusy)
+	assert.Equal(t, "1", metric(t, pool, "rejected"))
+
+	close(backend.release)
+	wg.Wait()
+
+	assert.Equal(t, "2", metric(t, pool, "completed"))
+	assert.Equal(t, "0", metric(t, pool, "queued"))
+	assert.Equal(t, "0", metric(t, pool, "active"))
+}
+
+func TestPoolQueueTimeout(t *testing.T) {
+	backend := newBlockingBackend()
+	pool := password.NewPool(backend, &config.Password{Workers: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, err := pool.Hash("qwerty")
+		assert.NoError(t, err)
+	}()
+
+	<-backend.started
+
+	_, err := pool.Hash("qwerty")
+	assert.ErrorIs(t, err, password.ErrBusy)
+	assert.Equal(t, "1", metric(t, pool, "timeouts"))
+
+	close(backend.release)
+	<-done
+}
+
+func TestPoolDefaultWorkers(t *testing.T) {
+	pool := password.NewPool(newBlockingBackend(), &config.Password{Algorithm: password.AlgArgon2id, Argon2Parallelism: 2})
+	assert.Equal(t, max(runtime.GOMAXPROCS(0)/4, 1), mustAtoi(t, metric(t, pool, "workers")))
+
+	pool = password.NewPool(newBlockingBackend(), &config.Password{Algorithm: password.AlgBcrypt, QueueSize: 8})
+	assert.Equal(t, max(runtime.GOMAXPROCS(0)/2, 1), mustAtoi(t, metric(t, pool, "workers")))
+	assert.Equal(t, "8", metric(t, pool, "queue_size"))
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+
+	n, err := strconv.Atoi(s)
+	require.NoError(t, err)
+
+	return n
+}
+
+func TestPoolDelegates(t *testing.T) {
+	pool := password.NewPool(mustNew(t, argon2Cfg), &argon2Cfg)
+
+	hash, err := pool.Hash("qwerty")
+	require.NoError(t, err)
+
+	rehash, err := pool.Verify(hash, "qwerty")
+	assert.NoError(t, err)
+	assert.False(t, rehash)
+
+	_, err = pool.Verify(hash, "QWERTY")
+	assert.ErrorIs(t, err, password.ErrMismatch)
+}
+
+func BenchmarkVerify(b *testing.B) {
+	h, err := password.New(&argon2Cfg)
+	require.NoError(b, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(b, err)
+
+	backends := []struct {
+		name    string
+		backend password.Backend
+	}{
+		{name: "inline", backend: h},
+		{name: "pool", backend: password.NewPool(h, &argon2Cfg)},
+	}
+
+	for _, bb := range backends {
+		b.Run(bb.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := bb.backend.Verify(hash, "qwerty"); err != nil && !errors.Is(err, password.ErrBusy) {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkLatencyUnderSignInLoad measures latency of a cheap endpoint while sign in requests
+// verify bcrypt hashes of cost 10 from 4 clients per CPU. Inline hashing takes every CPU,
+// so the cheap endpoint waits for the scheduler, while the pool leaves half of CPUs to it
+func BenchmarkLatencyUnderSignInLoad(b *testing.B) {
+	cfg := config.Password{Algorithm: password.AlgBcrypt, BcryptCost: 10, MaxLength: 72, QueueSize: 64, QueueTimeout: time.Second}
+
+	h, err := password.New(&cfg)
+	require.NoError(b, err)
+
+	hash, err := h.Hash("qwerty")
+	require.NoError(b, err)
+
+	backends := []struct {
+		name    string
+		backend password.Backend
+	}{
+		{name: "inline", backend: h},
+		{name: "pool", backend: password.NewPool(h, &cfg)},
+	}
+
+	for _, bb := range backends {
+		b.Run(bb.name, func(b *testing.B) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/sign-in", func(w http.ResponseWriter, _ *http.Request) {
+				if _, err := bb.backend.Verify(hash, "qwerty"); errors.Is(err, password.ErrBusy) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			})
+			mux.HandleFunc("/users/me", func(w http.ResponseWriter, _ *http.Request) {
+				json.NewEncoder(w).Encode(map[string]string{"name": "Jhon", "surname": "Doe"}) //nolint:errcheck
+			})
+
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 256}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			var wg sync.WaitGroup
+			for range 4 * runtime.GOMAXPROCS(0) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for ctx.Err() == nil {
+						resp, err := client.Post(srv.URL+"/sign-in", "application/json", nil)
+						if err != nil {
+							continue
+						}
+						io.Copy(io.Discard, resp.Body) //nolint:errcheck
+						resp.Body.Close()              //nolint:errcheck
+
+						// Rejected client backs off like a browser retrying later
+						if resp.StatusCode == http.StatusServiceUnavailable {
+							time.Sleep(10 * time.Millisecond)
+						}
+					}
+				}()
+			}
+
+			// Let the load saturate CPUs
+			time.Sleep(200 * time.Millisecond)
+
+			latencies := make([]time.Duration, 0, b.N)
+
+			b.ResetTimer()
+			for range b.N {
+				start := time.Now()
+
+				resp, err := client.Get(srv.URL + "/users/me")
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(io.Discard, resp.Body) //nolint:errcheck
+				resp.Body.Close()              //nolint:errcheck
+
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+
+			cancel()
+			wg.Wait()
+
+			slices.Sort(latencies)
+			b.ReportMetric(float64(latencies[len(latencies)/2].Microseconds()), "p50-µs")
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+		})
+	}
+}
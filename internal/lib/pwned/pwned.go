@@ -0,0 +1,214 @@
+// Package pwned looks passwords up in local copy of Have I Been Pwned SHA-1 password hashes,
+// so no password hash prefix ever leaves the service
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec // corpus is indexed by SHA-1
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"e-commerce-users/internal/config"
+)
+
+const (
+	ModeReject = "reject"
+	ModeWarn   = "warn"
+)
+
+const (
+	prefixLen = 5
+	hashLen   = 40
+	// maxLineLen bounds "HASH:COUNT\r\n" line of ordered corpus file
+	maxLineLen = 64
+)
+
+var ErrMalformed = errors.New("malformed corpus")
+
+// Corpus is either directory of range files named by 5 characters hash prefix, as served by
+// range API and saved by the official downloader, or single file of full hashes ordered by hash.
+// Both are searched on disk, so corpus size doesn't matter
+type Corpus struct {
+	path string
+	dir  bool
+	size int64
+}
+
+// New opens corpus configured for breach check. Without corpus path the check is disabled
+// and every password is reported as never breached
+func New(cfg *config.Password) (*Corpus, error) {
+	const op = "lib.pwned.New"
+
+	if cfg.BreachMode != ModeReject && cfg.BreachMode != ModeWarn {
+		return nil, fmt.Errorf("%s: unsupported breach mode %q", op, cfg.BreachMode)
+	}
+
+	if cfg.BreachCorpus == "" {
+		return &Corpus{}, nil
+	}
+
+	info, err := os.Stat(cfg.BreachCorpus)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Corpus{
+		path: cfg.BreachCorpus,
+		dir:  info.IsDir(),
+		size: info.Size(),
+	}, nil
+}
+
+// Count returns number of times password was seen in breaches, zero if it never was
+func (c *Corpus) Count(password string) (int, error) {
+	const op = "lib.pwned.Count"
+
+	if c.path == "" {
+		return 0, nil
+	}
+
+	sum := sha1.Sum([]byte(password)) //nolint:gosec // corpus is indexed by SHA-1
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var (
+		count int
+		err   error
+	)
+	if c.dir {
+		count, err = c.searchRange(hash)
+	} else {
+		count, err = c.searchOrdered(hash)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// searchRange scans range file of hash prefix, which lists hash suffixes with counts
+func (c *Corpus) searchRange(hash string) (int, error) {
+	f, err := os.Open(filepath.Join(c.path, hash[:prefixLen]+".txt"))
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	suffix := hash[prefixLen:]
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		s, cnt, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		if strings.EqualFold(s, suffix) {
+			return parseCount(cnt)
+		}
+	}
+
+	return 0, sc.Err()
+}
+
+// searchOrdered binary searches ordered file by byte offsets. Every step reads
+// a single line starting at or after the middle offset
+func (c *Corpus) searchOrdered(hash string) (int, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	buf := make([]byte, 2*maxLineLen)
+
+	lo, hi := int64(0), c.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := lineFrom(f, mid, buf)
+		if err != nil {
+			return 0, err
+		}
+
+		// No line starts within [mid, hi), so the hash can only be before mid
+		if line == nil || start >= hi {
+			hi = mid
+			continue
+		}
+
+		if len(line) < hashLen+2 || line[hashLen] != ':' {
+			return 0, fmt.Errorf("%w: unexpected line at offset %d", ErrMalformed, start)
+		}
+
+		switch cmp := strings.Compare(strings.ToUpper(string(line[:hashLen])), hash); {
+		case cmp == 0:
+			return parseCount(string(line[hashLen+1:]))
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lineFrom returns start offset and content of the first line starting at or after offset.
+// Line is nil if there is no such line
+func lineFrom(f *os.File, offset int64, buf []byte) (int64, []byte, error) {
+	// Line starts at offset if it's the file start or the previous byte is a line break
+	from := max(offset-1, 0)
+
+	n, err := f.ReadAt(buf, from)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, nil, err
+	}
+
+	data := buf[:n]
+
+	start := int64(0)
+	if offset > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return 0, nil, nil
+		}
+		data = data[i+1:]
+		start = from + int64(i) + 1
+	}
+
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		// The last line may have no line break, otherwise the line doesn't fit the buffer
+		if n == len(buf) {
+			return 0, nil, fmt.Errorf("%w: line at offset %d is too long", ErrMalformed, start)
+		}
+		end = len(data)
+	}
+
+	if end == 0 {
+		return start, nil, nil
+	}
+
+	return start, bytes.TrimRight(data[:end], "\r"), nil
+}
+
+func parseCount(s string) (int, error) {
+	count, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,149 @@
+package pwned_test
+
+import (
+	"crypto/sha1" //nolint:gosec // corpus is indexed by SHA-1
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"e-commerce-users/internal/config"
+	"e-commerce-users/internal/lib/pwned"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// breached is number of generated passwords in corpus, password "breached<i>" is seen i+1 times
+const breached = 300
+
+type entry struct {
+	hash  string
+	count int
+}
+
+func corpus() []entry {
+	entries := make([]entry, 0, breached)
+	for i := range breached {
+		sum := sha1.Sum([]byte(fmt.Sprintf("breached%d", i))) //nolint:gosec // corpus is indexed by SHA-1
+		entries = append(entries, entry{hash: strings.ToUpper(hex.EncodeToString(sum[:])), count: i + 1})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+
+	return entries
+}
+
+// writeOrdered writes ordered corpus file, trailing is removed from the file end
+func writeOrdered(t *testing.T, newline, trailing string) string {
+	t.Helper()
+
+	var b strings.Builder
+	for _, e := range corpus() {
+		fmt.Fprintf(&b, "%s:%d%s", e.hash, e.count, newline)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.TrimSuffix(b.String(), trailing)), 0o600))
+
+	return path
+}
+
+func writeRanges(t *testing.T) string {
+	t.Helper()
+
+	ranges := make(map[string]*strings.Builder)
+	for _, e := range corpus() {
+		b, ok := ranges[e.hash[:5]]
+		if !ok {
+			b = &strings.Builder{}
+			ranges[e.hash[:5]] = b
+		}
+		fmt.Fprintf(b, "%s:%d\r\n", e.hash[5:], e.count)
+	}
+
+	dir := t.TempDir()
+	for prefix, b := range ranges {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(b.String()), 0o600))
+	}
+
+	return dir
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		corpus string
+	}{
+		{
+			name:   "Ordered file",
+			corpus: writeOrdered(t, "\n", ""),
+		},
+		{
+			name:   "Ordered file without trailing line break",
+			corpus: writeOrdered(t, "\n", "\n"),
+		},
+		{
+			name:   "Ordered file with CRLF",
+			corpus: writeOrdered(t, "\r\n", ""),
+		},
+		{
+			name:   "Range files",
+			corpus: writeRanges(t),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := pwned.New(&config.Password{BreachCorpus: tt.corpus, BreachMode: pwned.ModeReject})
+			require.NoError(t, err)
+
+			for i := range breached {
+				count, err := c.Count(fmt.Sprintf("breached%d", i))
+				require.NoError(t, err)
+				assert.Equal(t, i+1, count, "breached%d", i)
+			}
+
+			for i := range breached {
+				count, err := c.Count(fmt.Sprintf("unseen%d", i))
+				// Range file of unseen password prefix is missing in the test corpus
+				if err != nil {
+					assert.ErrorIs(t, err, os.ErrNotExist)
+					continue
+				}
+				assert.Zero(t, count, "unseen%d", i)
+			}
+		})
+	}
+}
+
+func TestCountDisabled(t *testing.T) {
+	c, err := pwned.New(&config.Password{BreachMode: pwned.ModeWarn})
+	require.NoError(t, err)
+
+	count, err := c.Count("breached0")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestNew(t *testing.T) {
+	_, err := pwned.New(&config.Password{BreachMode: "ignore"})
+	assert.Error(t, err)
+
+	_, err = pwned.New(&config.Password{BreachCorpus: filepath.Join(t.TempDir(), "missing"), BreachMode: pwned.ModeReject})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCountMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not a corpus\nat all\n"), 0o600))
+
+	c, err := pwned.New(&config.Password{BreachCorpus: path, BreachMode: pwned.ModeReject})
+	require.NoError(t, err)
+
+	_, err = c.Count("password")
+	assert.ErrorIs(t, err, pwned.ErrMalformed)
+}
@@ -1,12 +1,21 @@
 package random
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
 	"math/rand/v2"
 )
 
 var table = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 
-const codeLen = 6
+const (
+	codeLen  = 6
+	tokenLen = 32
+	// recoveryCodeLen is the length of each of two recovery code groups
+	recoveryCodeLen = 5
+)
+
+var recoveryTable = "abcdefghijkmnpqrstuvwxyz23456789"
 
 func Code() string {
 	result := make([]byte, codeLen)
@@ -16,3 +25,32 @@ func Code() string {
 
 	return string(result)
 }
+
+// RecoveryCode returns cryptographically secure code formatted as "xxxxx-xxxxx".
+// Ambiguous characters are excluded from alphabet
+func RecoveryCode() string {
+	b := make([]byte, 2*recoveryCodeLen)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+
+	result := make([]byte, 0, 2*recoveryCodeLen+1)
+	for i, v := range b {
+		if i == recoveryCodeLen {
+			result = append(result, '-')
+		}
+		result = append(result, recoveryTable[int(v)%len(recoveryTable)])
+	}
+
+	return string(result)
+}
+
+// Token returns cryptographically secure URL-safe random string
+func Token() string {
+	b := make([]byte, tokenLen)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // RFC 6238 default algorithm supported by all authenticator apps
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	secretLen = 20
+	digits    = 6
+	period    = 30
+	// skew is the number of periods before and after the current one which codes are accepted
+	skew = 1
+)
+
+// Validity is the longest time a code is accepted since it is first seen: its own period
+// and skew periods before and after it
+const Validity = (2*skew + 1) * period * time.Second
+
+var ErrInvalidSecret = errors.New("invalid secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns random base32 encoded secret
+func GenerateSecret() (string, error) {
+	const op = "lib.totp.GenerateSecret"
+
+	b := make([]byte, secretLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns otpauth URI, which can be rendered as QR code for authenticator apps
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(digits))
+	v.Set("period", fmt.Sprint(period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+
+	return u.String()
+}
+
+// Code returns code for given time
+func Code(secret string, t time.Time) (string, error) {
+	const op = "lib.totp.Code"
+
+	key, err := decode(secret)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hotp(key, uint64(t.Unix()/period)), nil
+}
+
+// Validate checks code against given time allowing clock skew.
+// On success returns time step the code belongs to, so caller can prevent code reuse
+func Validate(code, secret string, t time.Time) (int64, bool) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.Unix() / period
+
+	for step := current - skew; step <= current+skew; step++ {
+		if hmac.Equal([]byte(hotp(key, uint64(step))), []byte(code)) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// hotp implements RFC 4226 HOTP value for given counter
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func decode(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
@@ -0,0 +1,70 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"e-commerce-users/internal/lib/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 Appendix B test vectors truncated to 6 digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tc := range tests {
+		code, err := totp.Code(secret, time.Unix(tc.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.code, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+
+	code, err := totp.Code(secret, now)
+	assert.NoError(t, err)
+
+	step, ok := totp.Validate(code, secret, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	// Previous period is accepted due to clock skew
+	_, ok = totp.Validate(code, secret, now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = totp.Validate(code, secret, now.Add(2*time.Minute))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate("000000", "not base32!", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Shop", "jhon@mail.com", "JBSWY3DPEHPK3PXP")
+
+	u, err := url.Parse(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Shop:jhon@mail.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Shop", u.Query().Get("issuer"))
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// FederatedIdentity is user account at external OpenID Connect provider, identified by provider and subject
+type FederatedIdentity struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginMethods are the ways user can sign in with. At least one of them is always kept
+type LoginMethods struct {
+	Password   bool
+	Identities []FederatedIdentity
+	Passkeys   []Passkey
+}
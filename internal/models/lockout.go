@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Lockout is temporary lock of account after too many wrong passwords
+type Lockout struct {
+	UserID      string    `json:"user_id"`
+	Email       string    `json:"email"`
+	LockedUntil time.Time `json:"locked_until"`
+}
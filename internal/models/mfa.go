@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// TOTP is time-based one-time password second factor of user
+type TOTP struct {
+	UserID    string
+	Secret    string
+	IsEnabled bool
+	CreatedAt time.Time
+}
+
+// RecoveryCode is one-time code which can be used instead of the second factor
+type RecoveryCode struct {
+	ID       string
+	UserID   string
+	CodeHash []byte
+}
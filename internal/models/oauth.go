@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Introspection is token state in RFC 7662 format. Inactive token carries no other fields
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Subject   string `json:"sub,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// OAuthClient is application allowed to sign users in through OpenID Connect or to get
+// service tokens with client_credentials grant. Public clients (SPA, mobile apps) have no secret
+// and rely on PKCE only. Scopes limit service tokens, user consent is limited by OpenID Connect scopes
+type OAuthClient struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	SecretHash   []byte    `json:"-"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (c *OAuthClient) IsPublic() bool {
+	return len(c.SecretHash) == 0
+}
+
+// AuthorizationRequest is authorization code flow request. Once approved,
+// it is stored under authorization code together with the user who approved it
+type AuthorizationRequest struct {
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	ResponseType        string `json:"response_type"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	UserID              string `json:"user_id,omitempty"`
+	MFA                 bool   `json:"mfa,omitempty"`
+}
+
+// TokenResponse is successful response of OAuth token endpoint
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Passkey is WebAuthn public key credential registered by user
+type Passkey struct {
+	ID              []byte
+	UserID          string
+	Name            string
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+	CreatedAt       time.Time
+	LastUsedAt      *time.Time
+}
@@ -3,14 +3,14 @@ package models
 import "time"
 
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Surname   string    `json:"surname"`
-	Birthdate time.Time `json:"birthdate"`
-	Role      string    `json:"role"`
-	IsActive  bool      `json:"-"`
-	Email     string    `json:"email"`
-	PassHash  []byte    `json:"-"`
-	Version   int       `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Surname   string     `json:"surname"`
+	Birthdate *time.Time `json:"birthdate,omitempty"`
+	Role      string     `json:"role"`
+	IsActive  bool       `json:"-"`
+	Email     string     `json:"email"`
+	PassHash  []byte     `json:"-"`
+	Version   int        `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
 }
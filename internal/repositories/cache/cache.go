@@ -3,15 +3,65 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"e-commerce-users/internal/repositories"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 const valBlacklisted = "blacklisted"
 
+// rotateFamily atomically replaces current family jti if it matches the expected one.
+// Returns -1 if family doesn't exist, 0 on jti mismatch and 1 on success
+var rotateFamily = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current then
+	return -1
+end
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// incrIfExists increments hash field only if the hash exists, so expired hash isn't recreated
+// without TTL. Returns -1 if hash doesn't exist
+var incrIfExists = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+return redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+`)
+
+// slidingWindow counts request in sorted set of request times within window, using Redis clock
+// so replicas agree on time. Returns whether request is allowed, requests left and milliseconds
+// until the oldest request leaves the window
+var slidingWindow = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+return {allowed, limit - count, tonumber(oldest[2]) + window - now}
+`)
+
 type Cache struct {
 	rc     *redis.Client
 	prefix string
@@ -45,10 +95,18 @@ func (c *Cache) AddToBlacklist(ctx context.Context, token string, ttl time.Durat
 	return nil
 }
 
+// SetConfirmationCode saves new confirmation code of email, wrong attempts of the previous one are forgotten
 func (c *Cache) SetConfirmationCode(ctx context.Context, email, code string, ttl time.Duration) error {
 	const op = "repositories.cache.SetConfirmationCode"
 
-	if _, err := c.rc.Set(ctx, c.prefix+email, code, ttl).Result(); err != nil {
+	key := c.confirmationKey(email)
+
+	pipe := c.rc.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "code", code, "attempts", 0)
+	pipe.PExpire(ctx, key, ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -56,9 +114,9 @@ func (c *Cache) SetConfirmationCode(ctx context.Context, email, code string, ttl
 }
 
 func (c *Cache) GetConfirmationCode(ctx context.Context, email string) (string, error) {
-	const op = "repositories.cache.SetConfirmationCode"
+	const op = "repositories.cache.GetConfirmationCode"
 
-	code, err := c.rc.Get(ctx, c.prefix+email).Result()
+	code, err := c.rc.HGet(ctx, c.confirmationKey(email), "code").Result()
 	if err != nil {
 		if err == redis.Nil {
 			return "", fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
@@ -70,10 +128,26 @@ func (c *Cache) GetConfirmationCode(ctx context.Context, email string) (string,
 	return code, nil
 }
 
+// IncrConfirmationAttempts counts wrong attempt to enter confirmation code and returns number of attempts made
+func (c *Cache) IncrConfirmationAttempts(ctx context.Context, email string) (int64, error) {
+	const op = "repositories.cache.IncrConfirmationAttempts"
+
+	attempts, err := incrIfExists.Run(ctx, c.rc, []string{c.confirmationKey(email)}, "attempts").Int64()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if attempts < 0 {
+		return 0, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return attempts, nil
+}
+
 func (c *Cache) RemoveConfirmationCode(ctx context.Context, email string) error {
 	const op = "repositories.cache.RemoveConfirmationCode"
 
-	_, err := c.rc.Del(ctx, c.prefix+email).Result()
+	_, err := c.rc.Del(ctx, c.confirmationKey(email)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
@@ -84,3 +158,408 @@ func (c *Cache) RemoveConfirmationCode(ctx context.Context, email string) error
 
 	return nil
 }
+
+func (c *Cache) confirmationKey(email string) string {
+	return fmt.Sprintf("%sconfirmation_%s", c.prefix, email)
+}
+
+func (c *Cache) SetActionToken(ctx context.Context, action, token, userID string, ttl time.Duration) error {
+	const op = "repositories.cache.SetActionToken"
+
+	if _, err := c.rc.Set(ctx, c.actionKey(action, token), userID, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetActionToken returns user ID bound to token without using the token up
+func (c *Cache) GetActionToken(ctx context.Context, action, token string) (string, error) {
+	const op = "repositories.cache.GetActionToken"
+
+	userID, err := c.rc.Get(ctx, c.actionKey(action, token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+// PopActionToken returns user ID bound to token and removes token, so it can be used only once
+func (c *Cache) PopActionToken(ctx context.Context, action, token string) (string, error) {
+	const op = "repositories.cache.PopActionToken"
+
+	userID, err := c.rc.GetDel(ctx, c.actionKey(action, token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+func (c *Cache) actionKey(action, token string) string {
+	return fmt.Sprintf("%s%s_%s", c.prefix, action, token)
+}
+
+func (c *Cache) GetUserVersion(ctx context.Context, userID string) (int, error) {
+	const op = "repositories.cache.GetUserVersion"
+
+	version, err := c.rc.Get(ctx, c.versionKey(userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return v, nil
+}
+
+func (c *Cache) SetUserVersion(ctx context.Context, userID string, version int, ttl time.Duration) error {
+	const op = "repositories.cache.SetUserVersion"
+
+	if _, err := c.rc.Set(ctx, c.versionKey(userID), version, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Cache) RemoveUserVersion(ctx context.Context, userID string) error {
+	const op = "repositories.cache.RemoveUserVersion"
+
+	if _, err := c.rc.Del(ctx, c.versionKey(userID)).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Cache) versionKey(userID string) string {
+	return fmt.Sprintf("%sversion_%s", c.prefix, userID)
+}
+
+// SetRefreshFamily stores jti of the only valid refresh token within the family
+func (c *Cache) SetRefreshFamily(ctx context.Context, familyID, jti string, ttl time.Duration) error {
+	const op = "repositories.cache.SetRefreshFamily"
+
+	if _, err := c.rc.Set(ctx, c.familyKey(familyID), jti, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetRefreshFamily returns jti of the only valid refresh token within the family
+func (c *Cache) GetRefreshFamily(ctx context.Context, familyID string) (string, error) {
+	const op = "repositories.cache.GetRefreshFamily"
+
+	jti, err := c.rc.Get(ctx, c.familyKey(familyID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return jti, nil
+}
+
+// RotateRefreshFamily replaces family jti with a new one if the current jti equals oldJTI
+func (c *Cache) RotateRefreshFamily(ctx context.Context, familyID, oldJTI, newJTI string, ttl time.Duration) error {
+	const op = "repositories.cache.RotateRefreshFamily"
+
+	res, err := rotateFamily.Run(ctx, c.rc, []string{c.familyKey(familyID)}, oldJTI, newJTI, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch res {
+	case -1:
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	case 0:
+		return fmt.Errorf("%s: %w", op, repositories.ErrMismatch)
+	}
+
+	return nil
+}
+
+func (c *Cache) RemoveRefreshFamily(ctx context.Context, familyID string) error {
+	const op = "repositories.cache.RemoveRefreshFamily"
+
+	if _, err := c.rc.Del(ctx, c.familyKey(familyID)).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Cache) familyKey(familyID string) string {
+	return fmt.Sprintf("%sfamily_%s", c.prefix, familyID)
+}
+
+// MarkTOTPUsed remembers TOTP time step used by user. Returns false if step has already been used
+func (c *Cache) MarkTOTPUsed(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error) {
+	const op = "repositories.cache.MarkTOTPUsed"
+
+	ok, err := c.rc.SetNX(ctx, fmt.Sprintf("%stotp_%s_%d", c.prefix, userID, step), step, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+// IncrAttempts increments attempts counter of given scope and returns its new value.
+// Counter expires after ttl since the first attempt
+func (c *Cache) IncrAttempts(ctx context.Context, scope, id string, ttl time.Duration) (int64, error) {
+	const op = "repositories.cache.IncrAttempts"
+
+	key := c.attemptsKey(scope, id)
+
+	pipe := c.rc.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return incr.Val(), nil
+}
+
+// GetAttempts returns attempts counter of given scope and time left until it expires
+func (c *Cache) GetAttempts(ctx context.Context, scope, id string) (int64, time.Duration, error) {
+	const op = "repositories.cache.GetAttempts"
+
+	key := c.attemptsKey(scope, id)
+
+	pipe := c.rc.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if get.Err() == redis.Nil {
+		return 0, 0, nil
+	}
+
+	attempts, err := get.Int64()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return attempts, ttl.Val(), nil
+}
+
+// ResetAttempts removes attempts counter of given scope
+func (c *Cache) ResetAttempts(ctx context.Context, scope, id string) error {
+	const op = "repositories.cache.ResetAttempts"
+
+	if _, err := c.rc.Del(ctx, c.attemptsKey(scope, id)).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *Cache) attemptsKey(scope, id string) string {
+	return fmt.Sprintf("%sattempts_%s_%s", c.prefix, scope, id)
+}
+
+// LockAccount forbids user to sign in with password for ttl
+func (c *Cache) LockAccount(ctx context.Context, userID string, ttl time.Duration) error {
+	const op = "repositories.cache.LockAccount"
+
+	if _, err := c.rc.Set(ctx, c.lockKey(userID), time.Now().Add(ttl).Unix(), ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAccountLock returns time left until account is unlocked, zero if it is not locked
+func (c *Cache) GetAccountLock(ctx context.Context, userID string) (time.Duration, error) {
+	const op = "repositories.cache.GetAccountLock"
+
+	ttl, err := c.rc.PTTL(ctx, c.lockKey(userID)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Negative TTL means there is no lock
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// UnlockAccount removes account lock
+func (c *Cache) UnlockAccount(ctx context.Context, userID string) error {
+	const op = "repositories.cache.UnlockAccount"
+
+	if _, err := c.rc.Del(ctx, c.lockKey(userID)).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListLockedAccounts returns IDs of locked users with time left until they are unlocked
+func (c *Cache) ListLockedAccounts(ctx context.Context) (map[string]time.Duration, error) {
+	const op = "repositories.cache.ListLockedAccounts"
+
+	prefix := c.lockKey("")
+	locks := make(map[string]time.Duration)
+
+	iter := c.rc.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		ttl, err := c.rc.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Lock could expire after scan
+		if ttl > 0 {
+			locks[strings.TrimPrefix(key, prefix)] = ttl
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return locks, nil
+}
+
+func (c *Cache) lockKey(userID string) string {
+	return fmt.Sprintf("%slocked_%s", c.prefix, userID)
+}
+
+// SetWebAuthnSession saves state of WebAuthn ceremony identified by its challenge
+func (c *Cache) SetWebAuthnSession(ctx context.Context, challenge string, data []byte, ttl time.Duration) error {
+	const op = "repositories.cache.SetWebAuthnSession"
+
+	if _, err := c.rc.Set(ctx, c.webAuthnKey(challenge), data, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PopWebAuthnSession returns state of WebAuthn ceremony and removes it, so challenge can be answered only once
+func (c *Cache) PopWebAuthnSession(ctx context.Context, challenge string) ([]byte, error) {
+	const op = "repositories.cache.PopWebAuthnSession"
+
+	data, err := c.rc.GetDel(ctx, c.webAuthnKey(challenge)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+func (c *Cache) webAuthnKey(challenge string) string {
+	return fmt.Sprintf("%swebauthn_%s", c.prefix, challenge)
+}
+
+// SetAuthCode saves authorization request approved by user under one-time authorization code
+func (c *Cache) SetAuthCode(ctx context.Context, code string, data []byte, ttl time.Duration) error {
+	const op = "repositories.cache.SetAuthCode"
+
+	if _, err := c.rc.Set(ctx, c.authCodeKey(code), data, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PopAuthCode returns authorization request and removes it, so code can be exchanged only once
+func (c *Cache) PopAuthCode(ctx context.Context, code string) ([]byte, error) {
+	const op = "repositories.cache.PopAuthCode"
+
+	data, err := c.rc.GetDel(ctx, c.authCodeKey(code)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+func (c *Cache) authCodeKey(code string) string {
+	return fmt.Sprintf("%sauth_code_%s", c.prefix, code)
+}
+
+// SetFederationState saves state of sign in with external provider until the user comes back
+func (c *Cache) SetFederationState(ctx context.Context, state string, data []byte, ttl time.Duration) error {
+	const op = "repositories.cache.SetFederationState"
+
+	if _, err := c.rc.Set(ctx, c.federationKey(state), data, ttl).Result(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PopFederationState returns state of sign in with external provider and removes it, so it can be completed only once
+func (c *Cache) PopFederationState(ctx context.Context, state string) ([]byte, error) {
+	const op = "repositories.cache.PopFederationState"
+
+	data, err := c.rc.GetDel(ctx, c.federationKey(state)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+func (c *Cache) federationKey(state string) string {
+	return fmt.Sprintf("%sfederation_%s", c.prefix, state)
+}
+
+// Allow counts request under key in sliding window and reports whether it fits limit
+func (c *Cache) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, error) {
+	const op = "repositories.cache.Allow"
+
+	res, err := slidingWindow.Run(ctx, c.rc,
+		[]string{c.prefix + "ratelimit_" + key},
+		window.Milliseconds(), limit, uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res[0] == 1, int(res[1]), time.Duration(res[2]) * time.Millisecond, nil
+}
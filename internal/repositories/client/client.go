@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ClientRepo struct {
+	db *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) *ClientRepo {
+	return &ClientRepo{
+		db: pool,
+	}
+}
+
+// Create saves new client. Returns ErrExists if client ID is taken
+func (cr *ClientRepo) Create(ctx context.Context, client *models.OAuthClient) error {
+	const op = "repositories.client.Create"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := cr.db.QueryRow(ctx, `
+	INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (id) DO NOTHING
+	RETURNING is_active, created_at
+	`, client.ID, client.Name, client.SecretHash, client.RedirectURIs, client.Scopes)
+
+	if err := row.Scan(&client.IsActive, &client.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, repositories.ErrExists)
+		}
+
+		log.Error("failed to create client", slog.String("client_id", client.ID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (cr *ClientRepo) List(ctx context.Context) ([]models.OAuthClient, error) {
+	const op = "repositories.client.List"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := cr.db.Query(ctx, `
+	SELECT id, name, secret_hash, redirect_uris, scopes, is_active, created_at
+	FROM oauth_clients
+	ORDER BY created_at`)
+	if err != nil {
+		log.Error("failed to list clients", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clients, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OAuthClient, error) {
+		var c models.OAuthClient
+
+		err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &c.RedirectURIs, &c.Scopes, &c.IsActive, &c.CreatedAt)
+
+		return c, err
+	})
+	if err != nil {
+		log.Error("failed to scan clients", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return clients, nil
+}
+
+// UpdateSecret replaces client secret, the previous one stops working immediately
+func (cr *ClientRepo) UpdateSecret(ctx context.Context, id string, secretHash []byte) error {
+	const op = "repositories.client.UpdateSecret"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := cr.db.Exec(ctx, `UPDATE oauth_clients SET secret_hash = $2 WHERE id = $1`, id, secretHash)
+	if err != nil {
+		log.Error("failed to update client secret", slog.String("client_id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (cr *ClientRepo) Disable(ctx context.Context, id string) error {
+	const op = "repositories.client.Disable"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := cr.db.Exec(ctx, `UPDATE oauth_clients SET is_active = FALSE WHERE id = $1`, id)
+	if err != nil {
+		log.Error("failed to disable client", slog.String("client_id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (cr *ClientRepo) GetByID(ctx context.Context, id string) (*models.OAuthClient, error) {
+	const op = "repositories.client.GetByID"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := cr.db.QueryRow(ctx, `
+	SELECT id, name, secret_hash, redirect_uris, scopes, is_active, created_at
+	FROM oauth_clients
+	WHERE id = $1`, id)
+
+	var client models.OAuthClient
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&client.SecretHash,
+		&client.RedirectURIs,
+		&client.Scopes,
+		&client.IsActive,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Info("client not found", slog.String("client_id", id))
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		log.Error("failed to get client", slog.String("client_id", id), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &client, nil
+}
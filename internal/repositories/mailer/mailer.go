@@ -4,6 +4,7 @@ import (
 	"e-commerce-users/internal/config"
 	"fmt"
 	"net/smtp"
+	"net/url"
 	"time"
 )
 
@@ -20,28 +21,88 @@ func New(cfg *config.SMTP) *Mailer {
 func (m *Mailer) Send(email, code string) error {
 	const op = "repositories.Mailer.Send"
 
+	if err := m.send(email, fmt.Sprintf("Your verification code: %s", code)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) SendResetToken(email, token string) error {
+	const op = "repositories.Mailer.SendResetToken"
+
+	if err := m.send(email, fmt.Sprintf("Your password reset token: %s", token)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) SendUnlockToken(email, token string) error {
+	const op = "repositories.Mailer.SendUnlockToken"
+
+	body := fmt.Sprintf("Your account was locked after too many failed sign in attempts. Your unlock token: %s", token)
+	if err := m.send(email, body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) SendSignUpAttempt(email string) error {
+	const op = "repositories.Mailer.SendSignUpAttempt"
+
+	body := "Someone tried to sign up with your email, but you already have an account. " +
+		"If it was you, sign in or reset your password, otherwise ignore this message"
+	if err := m.send(email, body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) SendMagicLink(email, token string) error {
+	const op = "repositories.Mailer.SendMagicLink"
+
+	body := fmt.Sprintf("Your sign in token: %s", token)
+	if m.cfg.MagicLinkURL != "" {
+		body = fmt.Sprintf("Sign in with this link: %s?token=%s", m.cfg.MagicLinkURL, url.QueryEscape(token))
+	}
+
+	if err := m.send(email, body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (m *Mailer) CodeTTL() time.Duration {
+	return m.cfg.CodeTTL
+}
+
+func (m *Mailer) ResetTTL() time.Duration {
+	return m.cfg.ResetTTL
+}
+
+func (m *Mailer) MagicLinkTTL() time.Duration {
+	return m.cfg.MagicLinkTTL
+}
+
+// send delivers message body to given email address
+func (m *Mailer) send(email, body string) error {
 	auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
 
 	msg := []byte(fmt.Sprintf(
 		`
 		From: %s
-		Your verification code: %s
-		`, m.cfg.Username, code,
+		%s
+		`, m.cfg.Username, body,
 	))
 
-	err := smtp.SendMail(
+	return smtp.SendMail(
 		fmt.Sprintf("%s:%s", m.cfg.Host, m.cfg.Port),
 		auth,
 		m.cfg.Username,
 		[]string{email},
 		msg)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
-
-func (m *Mailer) CodeTTL() time.Duration {
-	return m.cfg.CodeTTL
 }
@@ -0,0 +1,250 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type MFARepo struct {
+	db *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) *MFARepo {
+	return &MFARepo{
+		db: pool,
+	}
+}
+
+// SaveTOTP stores pending TOTP secret, replacing previous pending one.
+// Returns ErrExists if user already has TOTP enabled
+func (mr *MFARepo) SaveTOTP(ctx context.Context, userID, secret string) error {
+	const op = "repositories.mfa.SaveTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := mr.db.Exec(ctx, `
+	INSERT INTO totp_credentials (user_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, created_at = CURRENT_TIMESTAMP
+	WHERE totp_credentials.is_enabled = false
+	`, userID, secret)
+	if err != nil {
+		log.Error("failed to save totp secret", slog.String("user_id", userID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrExists)
+	}
+
+	return nil
+}
+
+func (mr *MFARepo) GetTOTP(ctx context.Context, userID string) (*models.TOTP, error) {
+	const op = "repositories.mfa.GetTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := mr.db.QueryRow(ctx, `
+	SELECT user_id, secret, is_enabled, created_at
+	FROM totp_credentials
+	WHERE user_id = $1`, userID)
+
+	var totp models.TOTP
+	err := row.Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.IsEnabled,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		log.Error("failed to get totp", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &totp, nil
+}
+
+func (mr *MFARepo) EnableTOTP(ctx context.Context, userID string) error {
+	const op = "repositories.mfa.EnableTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := mr.db.Exec(ctx, `UPDATE totp_credentials SET is_enabled = true WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Error("failed to enable totp", slog.String("user_id", userID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (mr *MFARepo) DeleteTOTP(ctx context.Context, userID string) error {
+	const op = "repositories.mfa.DeleteTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tx, err := mr.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	tag, err := tx.Exec(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Error("failed to delete totp", slog.String("user_id", userID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	// Recovery codes make no sense without the second factor
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Error("failed to delete recovery codes", slog.String("user_id", userID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes removes all recovery codes of user and saves the new ones
+func (mr *MFARepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error {
+	const op = "repositories.mfa.ReplaceRecoveryCodes"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tx, err := mr.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		log.Error("failed to delete recovery codes", slog.String("user_id", userID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(ctx, `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		VALUES ($1, $2)
+		`, userID, hash)
+		if err != nil {
+			log.Error("failed to insert recovery code", slog.String("user_id", userID), sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (mr *MFARepo) ListUnusedRecoveryCodes(ctx context.Context, userID string) ([]models.RecoveryCode, error) {
+	const op = "repositories.mfa.ListUnusedRecoveryCodes"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := mr.db.Query(ctx, `
+	SELECT id, user_id, code_hash
+	FROM recovery_codes
+	WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		log.Error("failed to list recovery codes", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.RecoveryCode, error) {
+		var c models.RecoveryCode
+		err := row.Scan(&c.ID, &c.UserID, &c.CodeHash)
+		return c, err
+	})
+	if err != nil {
+		log.Error("failed to scan recovery codes", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+func (mr *MFARepo) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	const op = "repositories.mfa.CountUnusedRecoveryCodes"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := mr.db.QueryRow(ctx, `
+	SELECT COUNT(*)
+	FROM recovery_codes
+	WHERE user_id = $1 AND used_at IS NULL`, userID)
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		log.Error("failed to count recovery codes", slog.String("user_id", userID), sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// UseRecoveryCode marks recovery code as used. Returns ErrNotFound if code has already been used
+func (mr *MFARepo) UseRecoveryCode(ctx context.Context, id string) error {
+	const op = "repositories.mfa.UseRecoveryCode"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := mr.db.Exec(ctx, `
+	UPDATE recovery_codes
+	SET used_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		log.Error("failed to use recovery code", slog.String("id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
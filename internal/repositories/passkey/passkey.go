@@ -0,0 +1,139 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PasskeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) *PasskeyRepo {
+	return &PasskeyRepo{
+		db: pool,
+	}
+}
+
+// Create saves new passkey. Returns ErrExists if credential is already registered
+func (pr *PasskeyRepo) Create(ctx context.Context, passkey *models.Passkey) error {
+	const op = "repositories.passkey.Create"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := pr.db.Exec(ctx, `
+	INSERT INTO webauthn_credentials (
+		id, user_id, name, public_key, attestation_type, transports,
+		aaguid, sign_count, backup_eligible, backup_state
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (id) DO NOTHING
+	`,
+		passkey.ID, passkey.UserID, passkey.Name, passkey.PublicKey, passkey.AttestationType, passkey.Transports,
+		passkey.AAGUID, int64(passkey.SignCount), passkey.BackupEligible, passkey.BackupState,
+	)
+	if err != nil {
+		log.Error("failed to create passkey", slog.String("user_id", passkey.UserID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrExists)
+	}
+
+	return nil
+}
+
+func (pr *PasskeyRepo) ListByUser(ctx context.Context, userID string) ([]models.Passkey, error) {
+	const op = "repositories.passkey.ListByUser"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := pr.db.Query(ctx, `
+	SELECT id, user_id, name, public_key, attestation_type, transports,
+		aaguid, sign_count, backup_eligible, backup_state, created_at, last_used_at
+	FROM webauthn_credentials
+	WHERE user_id = $1
+	ORDER BY created_at`, userID)
+	if err != nil {
+		log.Error("failed to list passkeys", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Passkey, error) {
+		var (
+			p         models.Passkey
+			signCount int64
+		)
+
+		err := row.Scan(
+			&p.ID, &p.UserID, &p.Name, &p.PublicKey, &p.AttestationType, &p.Transports,
+			&p.AAGUID, &signCount, &p.BackupEligible, &p.BackupState, &p.CreatedAt, &p.LastUsedAt,
+		)
+		p.SignCount = uint32(signCount) //nolint:gosec
+
+		return p, err
+	})
+	if err != nil {
+		log.Error("failed to scan passkeys", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passkeys, nil
+}
+
+// UpdateUsage saves sign counter and backup state reported by authenticator on sign in
+func (pr *PasskeyRepo) UpdateUsage(ctx context.Context, id []byte, signCount uint32, backupState bool) error {
+	const op = "repositories.passkey.UpdateUsage"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := pr.db.Exec(ctx, `
+	UPDATE webauthn_credentials
+	SET sign_count = $2, backup_state = $3, last_used_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	`, id, int64(signCount), backupState)
+	if err != nil {
+		log.Error("failed to update passkey", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Delete removes passkey owned by given user. Returns ErrLastLoginMethod if user can't sign in without it
+func (pr *PasskeyRepo) Delete(ctx context.Context, id []byte, userID string) error {
+	const op = "repositories.passkey.Delete"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	err := repositories.DeleteLoginMethod(ctx, pr.db, userID,
+		`DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) && !errors.Is(err, repositories.ErrLastLoginMethod) {
+			log.Error("failed to delete passkey", slog.String("user_id", userID), sl.Err(err))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
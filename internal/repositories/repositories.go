@@ -1,8 +1,62 @@
 package repositories
 
-import "errors"
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 var (
 	ErrNotFound = errors.New("not found")
 	ErrExists   = errors.New("already exits")
+	ErrMismatch = errors.New("mismatch")
+	// ErrLastLoginMethod is returned on attempt to remove the only way user can sign in with
+	ErrLastLoginMethod = errors.New("last login method")
 )
+
+// DeleteLoginMethod runs query removing one of user login methods: password, external identity or passkey.
+// Returns ErrNotFound if query deleted nothing and ErrLastLoginMethod if no other method remains
+func DeleteLoginMethod(ctx context.Context, db *pgxpool.Pool, userID, query string, args ...any) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	// Concurrent removals of the last two methods must not both succeed
+	tag, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	tag, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	var remaining int
+	err = tx.QueryRow(ctx, `
+	SELECT
+		(SELECT count(*) FROM local_credentials WHERE user_id = $1) +
+		(SELECT count(*) FROM federated_identities WHERE user_id = $1) +
+		(SELECT count(*) FROM webauthn_credentials WHERE user_id = $1)`, userID).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+
+	if remaining == 0 {
+		return ErrLastLoginMethod
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,162 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SessionRepo struct {
+	db *pgxpool.Pool
+}
+
+func New(pool *pgxpool.Pool) *SessionRepo {
+	return &SessionRepo{
+		db: pool,
+	}
+}
+
+// Create saves new session and removes expired sessions of the same user
+func (sr *SessionRepo) Create(ctx context.Context, session *models.Session) error {
+	const op = "repositories.session.Create"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tx, err := sr.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	_, err = tx.Exec(ctx, `
+	DELETE FROM sessions
+	WHERE user_id = $1 AND expires_at < CURRENT_TIMESTAMP
+	`, session.UserID)
+	if err != nil {
+		log.Error("failed to remove expired sessions", slog.String("user_id", session.UserID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO sessions (id, user_id, user_agent, ip, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`, session.ID, session.UserID, session.UserAgent, session.IP, session.ExpiresAt)
+	if err != nil {
+		log.Error("failed to create session", slog.String("user_id", session.UserID), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Touch updates session last usage time and prolongs its expiration
+func (sr *SessionRepo) Touch(ctx context.Context, id, ip string, expiresAt time.Time) error {
+	const op = "repositories.session.Touch"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := sr.db.Exec(ctx, `
+	UPDATE sessions
+	SET last_used_at = CURRENT_TIMESTAMP, ip = $2, expires_at = $3
+	WHERE id = $1
+	`, id, ip, expiresAt)
+	if err != nil {
+		log.Error("failed to update session", slog.String("id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (sr *SessionRepo) ListByUser(ctx context.Context, userID string) ([]models.Session, error) {
+	const op = "repositories.session.ListByUser"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := sr.db.Query(ctx, `
+	SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+	FROM sessions
+	WHERE user_id = $1 AND expires_at > CURRENT_TIMESTAMP
+	ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		log.Error("failed to list sessions", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Session, error) {
+		var s models.Session
+		err := row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt)
+		return s, err
+	})
+	if err != nil {
+		log.Error("failed to scan sessions", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// Delete removes session owned by given user
+func (sr *SessionRepo) Delete(ctx context.Context, id, userID string) error {
+	const op = "repositories.session.Delete"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := sr.db.Exec(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		log.Error("failed to delete session", slog.String("id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteByUser removes all sessions of user and returns their IDs
+func (sr *SessionRepo) DeleteByUser(ctx context.Context, userID string) ([]string, error) {
+	const op = "repositories.session.DeleteByUser"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := sr.db.Query(ctx, `DELETE FROM sessions WHERE user_id = $1 RETURNING id`, userID)
+	if err != nil {
+		log.Error("failed to delete sessions", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		log.Error("failed to scan deleted sessions", slog.String("user_id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
@@ -31,29 +31,28 @@ func (ur *UserRepo) GetByEmail(ctx context.Context, email string) (*models.User,
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
 
+	// Users without password are found by email of their external identities
 	row := ur.db.QueryRow(ctx, `
-	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active, lc.email, lc.pass_hash, version, u.created_at
+	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active, lc.email, lc.pass_hash, u.version, u.created_at
 	FROM
 		users u
 	JOIN
 		local_credentials lc
 	on
 		u.id = lc.user_id
-	WHERE lc.email = $1`, email)
+	WHERE lc.email = $1
+	UNION ALL
+	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active, fi.email, NULL, u.version, u.created_at
+	FROM
+		users u
+	JOIN
+		federated_identities fi
+	on
+		u.id = fi.user_id
+	WHERE fi.email = $1 AND NOT EXISTS (SELECT 1 FROM local_credentials lc WHERE lc.user_id = u.id)
+	LIMIT 1`, email)
 
-	var user models.User
-	err := row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Surname,
-		&user.Birthdate,
-		&user.Role,
-		&user.IsActive,
-		&user.Email,
-		&user.PassHash,
-		&user.Version,
-		&user.CreatedAt,
-	)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Info("user not found", slog.String("email", email))
@@ -64,7 +63,7 @@ func (ur *UserRepo) GetByEmail(ctx context.Context, email string) (*models.User,
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &user, err
+	return user, nil
 }
 
 func (ur *UserRepo) GetByID(ctx context.Context, id string) (*models.User, error) {
@@ -73,29 +72,22 @@ func (ur *UserRepo) GetByID(ctx context.Context, id string) (*models.User, error
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
 
+	// Users signed up with external provider have no password, their email is taken from the first identity
 	row := ur.db.QueryRow(ctx, `
-	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active, lc.email, lc.pass_hash, version, u.created_at
+	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active,
+		COALESCE(lc.email, fi.email, ''), lc.pass_hash, u.version, u.created_at
 	FROM
 		users u
-	JOIN
+	LEFT JOIN
 		local_credentials lc
 	on
 		u.id = lc.user_id
+	LEFT JOIN LATERAL (
+		SELECT email FROM federated_identities WHERE user_id = u.id ORDER BY created_at LIMIT 1
+	) fi ON true
 	WHERE u.id = $1`, id)
 
-	var user models.User
-	err := row.Scan(
-		&user.ID,
-		&user.Name,
-		&user.Surname,
-		&user.Birthdate,
-		&user.Role,
-		&user.IsActive,
-		&user.Email,
-		&user.PassHash,
-		&user.Version,
-		&user.CreatedAt,
-	)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			log.Info("user not found", slog.String("id", id))
@@ -106,7 +98,7 @@ func (ur *UserRepo) GetByID(ctx context.Context, id string) (*models.User, error
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &user, err
+	return user, nil
 }
 
 func (ur *UserRepo) CreateUser(ctx context.Context, name, surname, birthdate, email string, passHash []byte) error {
@@ -174,3 +166,315 @@ func (ur *UserRepo) ActivateUser(ctx context.Context, email string) error {
 
 	return nil
 }
+
+// UpdatePassword sets new password hash and increments credentials version,
+// which invalidates all tokens issued before
+func (ur *UserRepo) UpdatePassword(ctx context.Context, id string, passHash []byte) (int, error) {
+	const op = "repositories.auth.UpdatePassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := ur.db.QueryRow(ctx, `
+	WITH lc AS (
+		UPDATE local_credentials SET pass_hash = $2 WHERE user_id = $1 RETURNING user_id
+	)
+	UPDATE users
+	SET version = version + 1
+	WHERE id = (SELECT user_id FROM lc)
+	RETURNING version`, id, passHash)
+
+	var version int
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Info("user not found", slog.String("id", id))
+			return 0, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		log.Error("failed to update password", slog.String("id", id), sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// IncrementVersion increments credentials version, which invalidates all tokens issued before
+func (ur *UserRepo) IncrementVersion(ctx context.Context, id string) (int, error) {
+	const op = "repositories.auth.IncrementVersion"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := ur.db.QueryRow(ctx, `
+	UPDATE users
+	SET version = version + 1
+	WHERE id = $1
+	RETURNING version`, id)
+
+	var version int
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Info("user not found", slog.String("id", id))
+			return 0, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		log.Error("failed to increment version", slog.String("id", id), sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// GetByIdentity returns user owning external identity
+func (ur *UserRepo) GetByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	const op = "repositories.auth.GetByIdentity"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	row := ur.db.QueryRow(ctx, `
+	SELECT u.id, u.name, u.surname, u.birthdate, u.role, u.is_active,
+		COALESCE(lc.email, fi.email), lc.pass_hash, u.version, u.created_at
+	FROM
+		federated_identities fi
+	JOIN
+		users u
+	on
+		u.id = fi.user_id
+	LEFT JOIN
+		local_credentials lc
+	on
+		u.id = lc.user_id
+	WHERE fi.provider = $1 AND fi.subject = $2`, provider, subject)
+
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.Info("identity not found", slog.String("provider", provider))
+			return nil, fmt.Errorf("%s: %w", op, repositories.ErrNotFound)
+		}
+
+		log.Error("failed to get user by identity", slog.String("provider", provider), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// CreateFederatedUser creates active user without password, who signs in with external identity.
+// Returns ErrExists if identity is already linked to another user
+func (ur *UserRepo) CreateFederatedUser(ctx context.Context, user *models.User, identity *models.FederatedIdentity) error {
+	const op = "repositories.auth.CreateFederatedUser"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tx, err := ur.db.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	row := tx.QueryRow(ctx, `
+	INSERT INTO users (name, surname, is_active)
+	VALUES ($1, $2, true)
+	RETURNING id, role, version, created_at
+	`, user.Name, user.Surname)
+
+	if err := row.Scan(&user.ID, &user.Role, &user.Version, &user.CreatedAt); err != nil {
+		log.Error("failed to create user: inserting into users table", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.IsActive = true
+	identity.UserID = user.ID
+
+	if err := addIdentity(ctx, tx, identity); err != nil {
+		if !errors.Is(err, repositories.ErrExists) {
+			log.Error("failed to create user: inserting into federated_identities table", sl.Err(err))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddIdentity links external identity to existing user. Returns ErrExists if it is already linked
+func (ur *UserRepo) AddIdentity(ctx context.Context, identity *models.FederatedIdentity) error {
+	const op = "repositories.auth.AddIdentity"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if err := addIdentity(ctx, ur.db, identity); err != nil {
+		if !errors.Is(err, repositories.ErrExists) {
+			log.Error("failed to add identity", slog.String("id", identity.UserID), sl.Err(err))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListIdentities returns external identities linked to user
+func (ur *UserRepo) ListIdentities(ctx context.Context, userID string) ([]models.FederatedIdentity, error) {
+	const op = "repositories.auth.ListIdentities"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	rows, err := ur.db.Query(ctx, `
+	SELECT id, user_id, provider, subject, email, created_at
+	FROM federated_identities
+	WHERE user_id = $1
+	ORDER BY created_at`, userID)
+	if err != nil {
+		log.Error("failed to list identities", slog.String("id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identities, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.FederatedIdentity, error) {
+		var fi models.FederatedIdentity
+		err := row.Scan(&fi.ID, &fi.UserID, &fi.Provider, &fi.Subject, &fi.Email, &fi.CreatedAt)
+		return fi, err
+	})
+	if err != nil {
+		log.Error("failed to scan identities", slog.String("id", userID), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+// DeleteIdentity unlinks external identity from user. Returns ErrLastLoginMethod if user can't sign in without it
+func (ur *UserRepo) DeleteIdentity(ctx context.Context, id, userID string) error {
+	const op = "repositories.auth.DeleteIdentity"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	err := repositories.DeleteLoginMethod(ctx, ur.db, userID,
+		`DELETE FROM federated_identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) && !errors.Is(err, repositories.ErrLastLoginMethod) {
+			log.Error("failed to delete identity", slog.String("id", userID), sl.Err(err))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpgradePasswordHash replaces password hash with a stronger hash of the same password.
+// Version is kept, so issued tokens stay valid. Nothing is updated if the hash was changed meanwhile
+func (ur *UserRepo) UpgradePasswordHash(ctx context.Context, id string, oldHash, newHash []byte) error {
+	const op = "repositories.auth.UpgradePasswordHash"
+
+	if _, err := ur.db.Exec(ctx, `
+	UPDATE local_credentials SET pass_hash = $3
+	WHERE user_id = $1 AND pass_hash = $2`, id, oldHash, newHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreatePassword sets password of user who has none. Returns ErrExists if user already has password
+// or email is taken by another user
+func (ur *UserRepo) CreatePassword(ctx context.Context, id, email string, passHash []byte) error {
+	const op = "repositories.auth.CreatePassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	tag, err := ur.db.Exec(ctx, `
+	INSERT INTO local_credentials (user_id, email, pass_hash)
+	SELECT $1::uuid, $2::varchar, $3::bytea
+	WHERE NOT EXISTS (SELECT 1 FROM local_credentials WHERE user_id = $1)
+	ON CONFLICT (email) DO NOTHING
+	`, id, email, passHash)
+	if err != nil {
+		log.Error("failed to create password", slog.String("id", id), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repositories.ErrExists)
+	}
+
+	return nil
+}
+
+// DeletePassword removes user password. Returns ErrLastLoginMethod if user can't sign in without it
+func (ur *UserRepo) DeletePassword(ctx context.Context, id string) error {
+	const op = "repositories.auth.DeletePassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	err := repositories.DeleteLoginMethod(ctx, ur.db, id, `DELETE FROM local_credentials WHERE user_id = $1`, id)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) && !errors.Is(err, repositories.ErrLastLoginMethod) {
+			log.Error("failed to delete password", slog.String("id", id), sl.Err(err))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func addIdentity(ctx context.Context, q querier, identity *models.FederatedIdentity) error {
+	row := q.QueryRow(ctx, `
+	INSERT INTO federated_identities (user_id, provider, subject, email)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (provider, subject) DO NOTHING
+	RETURNING id, created_at
+	`, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+
+	if err := row.Scan(&identity.ID, &identity.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return repositories.ErrExists
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+
+	err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Surname,
+		&user.Birthdate,
+		&user.Role,
+		&user.IsActive,
+		&user.Email,
+		&user.PassHash,
+		&user.Version,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -2,21 +2,33 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"e-commerce-users/internal/config"
 	http_lib "e-commerce-users/internal/lib/http"
 	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/lib/oidc"
+	"e-commerce-users/internal/lib/passkey"
+	password_lib "e-commerce-users/internal/lib/password"
 	"e-commerce-users/internal/lib/random"
+	"e-commerce-users/internal/lib/totp"
 	"e-commerce-users/internal/models"
 	"e-commerce-users/internal/repositories"
 	"e-commerce-users/internal/services"
 	"e-commerce-users/pkg/logger/sl"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
 )
 
 type UserRepo interface {
@@ -24,6 +36,11 @@ type UserRepo interface {
 	GetByID(ctx context.Context, id string) (*models.User, error)
 	CreateUser(ctx context.Context, name, surname, birthdate, email string, passHash []byte) error
 	ActivateUser(ctx context.Context, email string) error
+	UpdatePassword(ctx context.Context, id string, passHash []byte) (int, error)
+	UpgradePasswordHash(ctx context.Context, id string, oldHash, newHash []byte) error
+	GetByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	CreateFederatedUser(ctx context.Context, user *models.User, identity *models.FederatedIdentity) error
+	AddIdentity(ctx context.Context, identity *models.FederatedIdentity) error
 }
 
 type Cache interface {
@@ -31,34 +48,156 @@ type Cache interface {
 	AddToBlacklist(ctx context.Context, token string, ttl time.Duration) error
 	SetConfirmationCode(ctx context.Context, email, code string, ttl time.Duration) error
 	GetConfirmationCode(ctx context.Context, email string) (string, error)
+	IncrConfirmationAttempts(ctx context.Context, email string) (int64, error)
 	RemoveConfirmationCode(ctx context.Context, email string) error
+	SetActionToken(ctx context.Context, action, token, userID string, ttl time.Duration) error
+	GetActionToken(ctx context.Context, action, token string) (string, error)
+	PopActionToken(ctx context.Context, action, token string) (string, error)
+	RemoveUserVersion(ctx context.Context, userID string) error
+	SetRefreshFamily(ctx context.Context, familyID, jti string, ttl time.Duration) error
+	RotateRefreshFamily(ctx context.Context, familyID, oldJTI, newJTI string, ttl time.Duration) error
+	RemoveRefreshFamily(ctx context.Context, familyID string) error
+	MarkTOTPUsed(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error)
+	IncrAttempts(ctx context.Context, scope, id string, ttl time.Duration) (int64, error)
+	GetAttempts(ctx context.Context, scope, id string) (int64, time.Duration, error)
+	ResetAttempts(ctx context.Context, scope, id string) error
+	LockAccount(ctx context.Context, userID string, ttl time.Duration) error
+	GetAccountLock(ctx context.Context, userID string) (time.Duration, error)
+	UnlockAccount(ctx context.Context, userID string) error
+	ListLockedAccounts(ctx context.Context) (map[string]time.Duration, error)
+	SetWebAuthnSession(ctx context.Context, challenge string, data []byte, ttl time.Duration) error
+	PopWebAuthnSession(ctx context.Context, challenge string) ([]byte, error)
+	SetFederationState(ctx context.Context, state string, data []byte, ttl time.Duration) error
+	PopFederationState(ctx context.Context, state string) ([]byte, error)
+}
+
+type SessionRepo interface {
+	Create(ctx context.Context, session *models.Session) error
+	Touch(ctx context.Context, id, ip string, expiresAt time.Time) error
+	Delete(ctx context.Context, id, userID string) error
+	DeleteByUser(ctx context.Context, userID string) ([]string, error)
+}
+
+type MFARepo interface {
+	GetTOTP(ctx context.Context, userID string) (*models.TOTP, error)
+	ListUnusedRecoveryCodes(ctx context.Context, userID string) ([]models.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id string) error
+}
+
+type PasskeyRepo interface {
+	ListByUser(ctx context.Context, userID string) ([]models.Passkey, error)
+	UpdateUsage(ctx context.Context, id []byte, signCount uint32, backupState bool) error
+}
+
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (bool, error)
 }
 
 type Mailer interface {
 	Send(email, code string) error
+	SendResetToken(email, token string) error
+	SendMagicLink(email, token string) error
+	SendUnlockToken(email, token string) error
+	SendSignUpAttempt(email string) error
 	CodeTTL() time.Duration
+	ResetTTL() time.Duration
+	MagicLinkTTL() time.Duration
 }
 
+const (
+	actionResetPassword = "reset_password"
+	actionMagicLink     = "magic_link"
+	actionUnlock        = "unlock"
+)
+
+// Scopes of failed sign in counters
+const (
+	attemptsSignIn   = "signin"
+	attemptsSignInIP = "signin_ip"
+	attemptsLockouts = "lockouts"
+	attemptsMFA      = "mfa"
+	// attemptsLockedEmail marks unknown email locked in anti-enumeration mode
+	attemptsLockedEmail = "locked_email"
+)
+
+// Scopes of sent confirmation codes counters
+const (
+	attemptsResendCooldown = "resend_cooldown"
+	attemptsResendDaily    = "resend_daily"
+)
+
+// lockoutsWindow is time locks are counted in to make every next one longer
+const lockoutsWindow = 24 * time.Hour
+
+// resendWindow is period confirmation codes daily limit applies to
+const resendWindow = 24 * time.Hour
+
 type Service struct {
-	usrRepo UserRepo
-	cache   Cache
-	mailer  Mailer
-	tknsCfg *config.Tokens
+	usrRepo   UserRepo
+	sessRepo  SessionRepo
+	mfaRepo   MFARepo
+	pkRepo    PasskeyRepo
+	cache     Cache
+	mailer    Mailer
+	hasher    PasswordHasher
+	passwords *services.Passwords
+	tknsCfg   *config.Tokens
+	keys      *jwt_lib.KeySet
+	mfaCfg    *config.MFA
+	lockCfg   *config.Lockout
+	confCfg   *config.Confirmation
+	webAuthn  *webauthn.WebAuthn
+	providers *oidc.Providers
+	fedCfg    *config.Federation
+	antiEnum  bool
+	dummyMu   sync.Mutex
+	dummyHash []byte
 }
 
 type Config struct {
-	Repo    UserRepo
-	Cache   Cache
-	Mailer  Mailer
-	TknsCfg *config.Tokens
+	Repo        UserRepo
+	SessionRepo SessionRepo
+	MFARepo     MFARepo
+	PasskeyRepo PasskeyRepo
+	Cache       Cache
+	Mailer      Mailer
+	Hasher      PasswordHasher
+	Policy      services.PasswordPolicy
+	Breaches    services.BreachChecker
+	TknsCfg     *config.Tokens
+	SigningKeys *jwt_lib.KeySet
+	MFACfg      *config.MFA
+	LockoutCfg  *config.Lockout
+	ConfirmCfg  *config.Confirmation
+	WebAuthn    *webauthn.WebAuthn
+	Providers   *oidc.Providers
+	FedCfg      *config.Federation
+	// RejectBreached refuses passwords found in breach corpus instead of only logging them
+	RejectBreached bool
+	// AntiEnumeration makes sign up, sign in and resend answer the same whether account exists or not
+	AntiEnumeration bool
 }
 
 func New(cfg *Config) *Service {
 	return &Service{
-		usrRepo: cfg.Repo,
-		cache:   cfg.Cache,
-		mailer:  cfg.Mailer,
-		tknsCfg: cfg.TknsCfg,
+		usrRepo:   cfg.Repo,
+		sessRepo:  cfg.SessionRepo,
+		mfaRepo:   cfg.MFARepo,
+		pkRepo:    cfg.PasskeyRepo,
+		cache:     cfg.Cache,
+		mailer:    cfg.Mailer,
+		hasher:    cfg.Hasher,
+		passwords: services.NewPasswords(cfg.Policy, cfg.Breaches, cfg.RejectBreached),
+		tknsCfg:   cfg.TknsCfg,
+		keys:      cfg.SigningKeys,
+		mfaCfg:    cfg.MFACfg,
+		lockCfg:   cfg.LockoutCfg,
+		confCfg:   cfg.ConfirmCfg,
+		webAuthn:  cfg.WebAuthn,
+		providers: cfg.Providers,
+		fedCfg:    cfg.FedCfg,
+		antiEnum:  cfg.AntiEnumeration,
 	}
 }
 
@@ -68,44 +207,54 @@ func (s *Service) SignUp(ctx context.Context, name, surname, birthdate, email, p
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
 
+	if err := s.passwords.Check(ctx, password, email, name, surname); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Password is hashed before the lookup, so existing accounts are not answered faster
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, services.HashingErr(err))
+	}
+
 	user, err := s.usrRepo.GetByEmail(ctx, email)
 	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if user != nil {
-		log.Debug("user already exists", slog.String("email", email))
-		return fmt.Errorf("%s: %w", op, services.ErrExists)
-	}
+	exists := user != nil
+	if !exists {
+		err := s.usrRepo.CreateUser(ctx,
+			name,
+			surname,
+			birthdate,
+			email,
+			passHash,
+		)
+		if err != nil && !errors.Is(err, repositories.ErrExists) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Error("failed to hash password")
-		return err
+		exists = err != nil
 	}
 
-	if err := s.usrRepo.CreateUser(ctx,
-		name,
-		surname,
-		birthdate,
-		email,
-		passHash,
-	); err != nil {
-		if errors.Is(err, repositories.ErrExists) {
+	if exists {
+		log.Debug("user already exists", slog.String("email", email))
+
+		if !s.antiEnum {
 			return fmt.Errorf("%s: %w", op, services.ErrExists)
 		}
 
-		return fmt.Errorf("%s: %w", op, err)
-	}
+		if err := s.notifySignUpAttempt(ctx, email); err != nil {
+			log.Error("failed to notify user about sign up attempt", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
-	// Generate & send confirmation code
-	code := random.Code()
-	if err := s.cache.SetConfirmationCode(ctx, email, code, s.mailer.CodeTTL()); err != nil {
-		log.Error("failed to put confirmation code to cache", sl.Err(err))
-		return fmt.Errorf("%s: %w", op, err)
+		return nil
 	}
 
-	if err := s.mailer.Send(email, code); err != nil {
+	if err := s.sendConfirmationCode(ctx, email); err != nil {
 		log.Error("failed to send verification code", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -113,59 +262,228 @@ func (s *Service) SignUp(ctx context.Context, name, surname, birthdate, email, p
 	return nil
 }
 
+// notifySignUpAttempt tells account owner that someone tried to sign up with their email.
+// Notices are counted along with confirmation codes, so they can't flood the inbox
+// and limits of the email don't tell whether account exists
+func (s *Service) notifySignUpAttempt(ctx context.Context, email string) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	if err := s.checkResendLimits(ctx, email); err != nil {
+		log.Warn("sign up attempt notice is not sent", slog.String("email", email), sl.Err(err))
+		return nil
+	}
+
+	if err := s.countConfirmationCode(ctx, email); err != nil {
+		return err
+	}
+
+	return s.mailer.SendSignUpAttempt(email)
+}
+
 func (s *Service) SignIn(ctx context.Context, email, password string) (string, string, error) {
 	const op = "services.auth.SignIn"
 
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
 
+	ip := http_lib.GetCtxClient(ctx).IP
+
+	if err := s.checkIPAttempts(ctx, ip); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
 	user, err := s.usrRepo.GetByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, repositories.ErrNotFound) {
 			log.Warn("email not found", slog.String("email", email))
+
+			if s.antiEnum {
+				return "", "", fmt.Errorf("%s: %w", op, s.signInUnknown(ctx, email, password, ip))
+			}
+
+			if err := s.registerSignInFailure(ctx, nil, ip); err != nil {
+				return "", "", fmt.Errorf("%s: %w", op, err)
+			}
+
 			return "", "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
 		}
 
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = bcrypt.CompareHashAndPassword(user.PassHash, []byte(password))
+	// Lock is checked before password, so guessing goes on without learning anything
+	if err := s.CheckAccountLock(ctx, user.ID); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Users signed up with external provider have no password, guessing it locks them like any other account
+	if user.PassHash == nil {
+		log.Warn("user has no password", slog.String("email", email))
+
+		if s.antiEnum {
+			if err := s.verifyDummy(password); err != nil {
+				log.Error("failed to compare password hash", sl.Err(err))
+				return "", "", fmt.Errorf("%s: %w", op, services.HashingErr(err))
+			}
+		}
+
+		if err := s.registerSignInFailure(ctx, user, ip); err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrInvalidCredentials)
+	}
+
+	rehash, err := s.hasher.Verify(user.PassHash, password)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if errors.Is(err, password_lib.ErrMismatch) {
 			log.Warn("invalid password", slog.String("email", email))
+
+			if err := s.registerSignInFailure(ctx, user, ip); err != nil {
+				return "", "", fmt.Errorf("%s: %w", op, err)
+			}
+
 			return "", "", fmt.Errorf("%s: %w", op, services.ErrInvalidCredentials)
 		}
 
-		log.Debug("failed to compare password hash", sl.Err(err))
-		return "", "", err
+		log.Error("failed to compare password hash", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.HashingErr(err))
 	}
 
-	accessToken, err := jwt_lib.NewAccessToken(
-		user.ID,
-		user.Role,
-		user.Version,
-		time.Now().Add(s.tknsCfg.AccessTTL),
-		s.tknsCfg.Secret,
-	)
-	if err != nil {
-		log.Error("failed to generate access token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %s", op, err)
+	if err := s.cache.ResetAttempts(ctx, attemptsSignIn, user.ID); err != nil {
+		log.Warn("failed to reset failed sign ins", sl.Err(err))
 	}
 
-	refreshToken, err := jwt_lib.NewRefreshToken(
-		user.ID,
-		user.Version,
-		time.Now().Add(s.tknsCfg.RefreshTTL),
-		s.tknsCfg.Secret,
-	)
+	if rehash {
+		s.upgradePasswordHash(ctx, user, password)
+	}
+
+	accessToken, refreshToken, err := s.completeSignIn(ctx, user)
 	if err != nil {
-		log.Error("failed to generate refresh token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %s", op, err)
+		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return accessToken, refreshToken, nil
 }
 
+// signInUnknown answers sign in to unknown email the way sign in to existing account is answered
+// in anti-enumeration mode: lock is checked, password is verified and failures lock the email
+func (s *Service) signInUnknown(ctx context.Context, email, password, ip string) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	id := emailLockID(email)
+
+	locked, retryAfter, err := s.cache.GetAttempts(ctx, attemptsLockedEmail, id)
+	if err != nil {
+		log.Error("failed to get email lock", sl.Err(err))
+		return err
+	}
+
+	if locked > 0 {
+		log.Warn("unknown email is locked")
+		return &services.LockedError{RetryAfter: retryAfter}
+	}
+
+	if err := s.verifyDummy(password); err != nil {
+		log.Error("failed to compare password hash", sl.Err(err))
+		return services.HashingErr(err)
+	}
+
+	if err := s.registerSignInFailure(ctx, nil, ip); err != nil {
+		return err
+	}
+
+	lock, err := s.countAccountFailure(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !lock {
+		return services.ErrInvalidCredentials
+	}
+
+	duration, err := s.lockDuration(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.cache.IncrAttempts(ctx, attemptsLockedEmail, id, duration); err != nil {
+		return err
+	}
+
+	log.Warn("unknown email locked", slog.Duration("duration", duration))
+
+	return &services.LockedError{RetryAfter: duration}
+}
+
+// emailLockID identifies unknown email in sign in counters without keeping it in plain text
+func emailLockID(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyDummy verifies password against hash of a random one, so sign in to unknown account
+// takes as long as to existing one. Mismatch is expected, other errors are returned
+func (s *Service) verifyDummy(password string) error {
+	s.dummyMu.Lock()
+	hash := s.dummyHash
+	s.dummyMu.Unlock()
+
+	if hash == nil {
+		var err error
+
+		hash, err = s.hasher.Hash(random.Token())
+		if err != nil {
+			return err
+		}
+
+		s.dummyMu.Lock()
+		s.dummyHash = hash
+		s.dummyMu.Unlock()
+	}
+
+	if _, err := s.hasher.Verify(hash, password); err != nil && !errors.Is(err, password_lib.ErrMismatch) {
+		return err
+	}
+
+	return nil
+}
+
+// upgradePasswordHash replaces hash made with outdated algorithm or parameters while the password is known.
+// Failure is not fatal, the hash is upgraded on the next sign in
+func (s *Service) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	log := http_lib.GetCtxLogger(ctx)
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Warn("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := s.usrRepo.UpgradePasswordHash(ctx, user.ID, user.PassHash, passHash); err != nil {
+		log.Warn("failed to upgrade password hash", sl.Err(err))
+		return
+	}
+
+	log.Info("password hash upgraded", slog.String("id", user.ID))
+}
+
+// IsBlacklisted reports whether token was revoked by logout
+func (s *Service) IsBlacklisted(ctx context.Context, token string) (bool, error) {
+	const op = "services.auth.IsBlacklisted"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	blacklisted, err := s.cache.IsBlacklisted(ctx, token)
+	if err != nil {
+		log.Error("failed to check if token blacklisted", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return blacklisted, nil
+}
+
 func (s *Service) Logout(ctx context.Context, accessToken, refreshToken string) error {
 	const op = "services.auth.Logout"
 
@@ -196,7 +514,7 @@ func (s *Service) Logout(ctx context.Context, accessToken, refreshToken string)
 		return fmt.Errorf("%s: %w", op, services.ErrTokenBlacklisted)
 	}
 
-	accClaims, err := jwt_lib.FromString(accessToken, s.tknsCfg.Secret)
+	accClaims, err := jwt_lib.FromString(accessToken, s.keys)
 	if err != nil && !errors.Is(err, jwt_lib.ErrExpired) {
 		if errors.Is(err, jwt_lib.ErrInvalid) {
 			log.Error("token invalid", sl.Err(err))
@@ -219,7 +537,7 @@ func (s *Service) Logout(ctx context.Context, accessToken, refreshToken string)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	rfrshClaims, err := jwt_lib.FromString(refreshToken, s.tknsCfg.Secret)
+	rfrshClaims, err := jwt_lib.FromString(refreshToken, s.keys)
 	if err != nil {
 		if errors.Is(err, jwt_lib.ErrExpired) {
 			log.Warn("token expired", sl.Err(err))
@@ -251,6 +569,19 @@ func (s *Service) Logout(ctx context.Context, accessToken, refreshToken string)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if familyID, err := jwt_lib.GetClaim(rfrshClaims, "fam"); err == nil {
+		if err := s.cache.RemoveRefreshFamily(ctx, familyID); err != nil {
+			log.Error("failed to revoke token family", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		userID, _ := jwt_lib.GetClaim(rfrshClaims, "sub")
+		if err := s.sessRepo.Delete(ctx, familyID, userID); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			log.Error("failed to remove session", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	if err := s.cache.AddToBlacklist(ctx, accessToken, time.Until(accExpTime)); err != nil {
 		log.Error("failed to blacklist access token", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
@@ -277,6 +608,25 @@ func (s *Service) Confirm(ctx context.Context, email, code string) (string, stri
 
 	if code != correctCode {
 		log.Warn("given incorrect confirmation code")
+
+		attempts, err := s.cache.IncrConfirmationAttempts(ctx, email)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			log.Error("failed to count confirmation attempt", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Code can't be guessed within a few attempts, so after them user has to request a new one
+		if s.confCfg.MaxAttempts > 0 && attempts >= int64(s.confCfg.MaxAttempts) {
+			log.Warn("confirmation code attempts exhausted", slog.String("email", email))
+
+			if err := s.cache.RemoveConfirmationCode(ctx, email); err != nil {
+				log.Error("failed to remove confirmation code", sl.Err(err))
+				return "", "", fmt.Errorf("%s: %w", op, err)
+			}
+
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrCodeExhausted)
+		}
+
 		return "", "", fmt.Errorf("%s: %w", op, services.ErrCode)
 	}
 
@@ -299,70 +649,134 @@ func (s *Service) Confirm(ctx context.Context, email, code string) (string, stri
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	accessToken, err := jwt_lib.NewAccessToken(
-		user.ID,
-		user.Role,
-		user.Version,
-		time.Now().Add(s.tknsCfg.AccessTTL),
-		s.tknsCfg.Secret,
-	)
-	if err != nil {
-		log.Error("failed to generate access token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %s", op, err)
-	}
-
-	refreshToken, err := jwt_lib.NewRefreshToken(
-		user.ID,
-		user.Version,
-		time.Now().Add(s.tknsCfg.RefreshTTL),
-		s.tknsCfg.Secret,
-	)
+	accessToken, refreshToken, err := s.StartSession(ctx, user, false, nil)
 	if err != nil {
-		log.Error("failed to generate refresh token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %s", op, err)
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return accessToken, refreshToken, nil
 }
 
+// ResendCode emails new confirmation code. Codes are sent not more often than once per cooldown
+// and not more than daily limit, so the endpoint can't be used to flood an inbox
 func (s *Service) ResendCode(ctx context.Context, email string) error {
-	const op = "services.auth.Confirm"
+	const op = "services.auth.ResendCode"
 
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
 
+	// Limits apply to any email, so they don't tell whether account exists
+	if err := s.checkResendLimits(ctx, email); err != nil {
+		log.Warn("confirmation code requested too often", slog.String("email", email), sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	user, err := s.usrRepo.GetByEmail(ctx, email)
-	if err != nil {
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
 		log.Error("failed to get user by email", sl.Err(err))
-		if errors.Is(err, repositories.ErrNotFound) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user == nil || user.IsActive {
+		log.Info("confirmation code requested for unknown or active user", slog.String("email", email))
+
+		if !s.antiEnum {
+			if user == nil {
+				return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, services.ErrNoActionRequired)
+		}
+
+		// Nothing is sent, but the request is counted as if it was
+		if err := s.countConfirmationCode(ctx, email); err != nil {
+			log.Error("failed to count confirmation code", sl.Err(err))
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
+		return nil
+	}
+
+	if err := s.sendConfirmationCode(ctx, email); err != nil {
+		log.Error("failed to send confirmation code to email", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if user.IsActive {
-		log.Info("user already active")
-		return fmt.Errorf("%s: %w", op, services.ErrNoActionRequired)
+	return nil
+}
+
+// checkResendLimits returns RateLimitedError within cooldown after the last code and
+// QuotaExceededError if daily limit is reached
+func (s *Service) checkResendLimits(ctx context.Context, email string) error {
+	if s.confCfg.ResendCooldown > 0 {
+		sent, ttl, err := s.cache.GetAttempts(ctx, attemptsResendCooldown, email)
+		if err != nil {
+			return err
+		}
+
+		if sent > 0 {
+			return &services.RateLimitedError{RetryAfter: ttl}
+		}
+	}
+
+	if s.confCfg.DailyLimit > 0 {
+		sent, ttl, err := s.cache.GetAttempts(ctx, attemptsResendDaily, email)
+		if err != nil {
+			return err
+		}
+
+		if sent >= int64(s.confCfg.DailyLimit) {
+			return &services.QuotaExceededError{RetryAfter: ttl}
+		}
+	}
+
+	return nil
+}
+
+// sendConfirmationCode replaces confirmation code of email with a new one and sends it.
+// Code is counted before sending, so failed sends can't be retried without limit either
+func (s *Service) sendConfirmationCode(ctx context.Context, email string) error {
+	if err := s.countConfirmationCode(ctx, email); err != nil {
+		return err
 	}
 
 	code := random.Code()
-
 	if err := s.cache.SetConfirmationCode(ctx, email, code, s.mailer.CodeTTL()); err != nil {
-		log.Error("failed to set confirmation code into cache", sl.Err(err))
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
-	if err := s.mailer.Send(email, code); err != nil {
-		log.Error("failed to send confirmation code to email", sl.Err(err))
-		return fmt.Errorf("%s: %w", op, err)
+	return s.mailer.Send(email, code)
+}
+
+// countConfirmationCode counts code sent to email towards resend cooldown and daily limit
+func (s *Service) countConfirmationCode(ctx context.Context, email string) error {
+	if s.confCfg.ResendCooldown > 0 {
+		if _, err := s.cache.IncrAttempts(ctx, attemptsResendCooldown, email, s.confCfg.ResendCooldown); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	_, err := s.cache.IncrAttempts(ctx, attemptsResendDaily, email, resendWindow)
+
+	return err
 }
 
+// Refresh rotates refresh token within its family. Presenting already rotated
+// refresh token is treated as token theft and revokes the whole family
 func (s *Service) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
-	const op = "services.auth.Refresh"
+	return s.refresh(ctx, refreshToken, "")
+}
+
+// RefreshGrant rotates refresh token issued to OAuth client with clientID
+func (s *Service) RefreshGrant(ctx context.Context, refreshToken, clientID string) (string, string, error) {
+	return s.refresh(ctx, refreshToken, clientID)
+}
+
+// refresh rotates refresh token of clientID, empty for first-party sessions. Tokens granted to
+// OAuth clients can't be refreshed as first-party ones, so they stay bound to client authentication
+func (s *Service) refresh(ctx context.Context, refreshToken, clientID string) (string, string, error) {
+	const op = "services.auth.refresh"
 
 	log := http_lib.GetCtxLogger(ctx)
 	log = log.With(slog.String("op", op))
@@ -378,7 +792,7 @@ func (s *Service) Refresh(ctx context.Context, refreshToken string) (string, str
 		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenBlacklisted)
 	}
 
-	claims, err := jwt_lib.FromString(refreshToken, s.tknsCfg.Secret)
+	claims, err := jwt_lib.FromString(refreshToken, s.keys)
 	if err != nil {
 		if errors.Is(err, jwt_lib.ErrExpired) {
 			log.Error("token expired", sl.Err(err))
@@ -400,56 +814,1164 @@ func (s *Service) Refresh(ctx context.Context, refreshToken string) (string, str
 		return "", "", fmt.Errorf("%s: %w", op, services.ErrUnexpectedTokenType)
 	}
 
+	grant := jwt_lib.GrantFromClaims(claims)
+
+	var grantClientID string
+	if grant != nil {
+		grantClientID = grant.ClientID
+	}
+
+	if grantClientID != clientID {
+		log.Warn("refresh token was issued to another client", slog.String("client_id", grantClientID))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
 	userID, err := jwt_lib.GetClaim(claims, "sub")
 	if err != nil {
 		log.Error("failed to get user ID from claims", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	user, err := s.usrRepo.GetByID(ctx, userID)
+	jti, err := jwt_lib.GetClaim(claims, "jti")
 	if err != nil {
-		log.Error("failed to get user by id", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		log.Warn("failed to get jti from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
 	}
 
-	accTkn, err := jwt_lib.NewAccessToken(
-		user.ID,
-		user.Role,
-		user.Version,
-		time.Now().Add(s.tknsCfg.AccessTTL),
-		s.tknsCfg.Secret,
-	)
+	familyID, err := jwt_lib.GetClaim(claims, "fam")
 	if err != nil {
-		log.Error("failed to generate access token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		log.Warn("failed to get family ID from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
 	}
 
-	rfrshTkn, err := jwt_lib.NewRefreshToken(
-		user.ID,
-		user.Version,
-		time.Now().Add(s.tknsCfg.RefreshTTL),
-		s.tknsCfg.Secret,
-	)
+	user, err := s.usrRepo.GetByID(ctx, userID)
 	if err != nil {
-		log.Error("failed to generate refresh token", sl.Err(err))
+		log.Error("failed to get user by id", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	expStr, err := jwt_lib.GetClaim(claims, "exp")
+	version, err := jwt_lib.GetIntClaim(claims, "version")
 	if err != nil {
-		log.Error("failed to get expiration time from claims", sl.Err(err))
+		log.Error("failed to get version from claims", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	expTime, err := time.Parse(time.RFC3339, expStr)
-	if err != nil {
-		log.Error("failed to parse expiration time", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+	if version != user.Version {
+		log.Warn("token version is outdated", slog.String("id", user.ID))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenRevoked)
 	}
 
-	if err := s.cache.AddToBlacklist(ctx, refreshToken, time.Until(expTime)); err != nil {
+	newJTI := uuid.NewString()
+
+	if err := s.cache.RotateRefreshFamily(ctx, familyID, jti, newJTI, s.tknsCfg.RefreshTTL); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("token family revoked", slog.String("family_id", familyID))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenRevoked)
+		}
+
+		if errors.Is(err, repositories.ErrMismatch) {
+			log.Warn("security event: refresh token reuse detected, revoking token family",
+				slog.String("event", "refresh_token_reuse"),
+				slog.String("user_id", user.ID),
+				slog.String("family_id", familyID),
+				slog.String("jti", jti),
+			)
+
+			if err := s.cache.RemoveRefreshFamily(ctx, familyID); err != nil {
+				log.Error("failed to revoke token family", sl.Err(err))
+				return "", "", fmt.Errorf("%s: %w", op, err)
+			}
+
+			if err := s.sessRepo.Delete(ctx, familyID, user.ID); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				log.Error("failed to remove session", sl.Err(err))
+			}
+
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenReused)
+		}
+
+		log.Error("failed to rotate token family", sl.Err(err))
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	mfa, _ := jwt_lib.GetBoolClaim(claims, "mfa")
+
+	accTkn, rfrshTkn, err := s.issueTokens(user, newJTI, familyID, mfa, grant)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	client := http_lib.GetCtxClient(ctx)
+	if err := s.sessRepo.Touch(ctx, familyID, client.IP, time.Now().Add(s.tknsCfg.RefreshTTL)); err != nil {
+		log.Warn("failed to update session", slog.String("session_id", familyID), sl.Err(err))
+	}
+
 	return accTkn, rfrshTkn, nil
 }
+
+// SignInMFA redeems mfa_pending token issued by SignIn together with TOTP code.
+// If recoveryCode is not empty, it is used instead of TOTP code
+func (s *Service) SignInMFA(ctx context.Context, mfaToken, code, recoveryCode string) (string, string, error) {
+	const op = "services.auth.SignInMFA"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	blacklisted, err := s.cache.IsBlacklisted(ctx, mfaToken)
+	if err != nil {
+		log.Error("failed to check if token blacklisted", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if blacklisted {
+		log.Warn("token is blacklisted")
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenBlacklisted)
+	}
+
+	claims, err := jwt_lib.FromString(mfaToken, s.keys)
+	if err != nil {
+		if errors.Is(err, jwt_lib.ErrExpired) {
+			log.Warn("token expired", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenExpired)
+		}
+
+		log.Warn("failed to extract token claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	tknType, err := jwt_lib.GetClaim(claims, "type")
+	if err != nil || tknType != "mfa_pending" {
+		log.Warn("unexpected token type: expected 'mfa_pending'")
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrUnexpectedTokenType)
+	}
+
+	userID, err := jwt_lib.GetClaim(claims, "sub")
+	if err != nil {
+		log.Error("failed to get user ID from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	expStr, err := jwt_lib.GetClaim(claims, "exp")
+	if err != nil {
+		log.Error("failed to get expiration time from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	expTime, err := time.Parse(time.RFC3339, expStr)
+	if err != nil {
+		log.Error("failed to parse expiration time", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.usrRepo.GetByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user by id", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Account locked after wrong passwords or codes accepts no more guesses
+	if err := s.CheckAccountLock(ctx, userID); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	otp, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("totp not found", slog.String("id", userID))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrInvalidCredentials)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !otp.IsEnabled {
+		log.Warn("totp is not enabled", slog.String("id", userID))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrInvalidCredentials)
+	}
+
+	var ok bool
+	if recoveryCode != "" {
+		ok, err = s.useRecoveryCode(ctx, userID, recoveryCode)
+	} else {
+		ok, err = s.useTOTPCode(ctx, userID, otp.Secret, code)
+	}
+	if err != nil {
+		log.Error("failed to verify second factor", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !ok {
+		log.Warn("invalid second factor code", slog.String("id", userID))
+		if err := s.registerMFAFailure(ctx, user, mfaToken, time.Until(expTime)); err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrCode)
+	}
+
+	if err := s.cache.AddToBlacklist(ctx, mfaToken, time.Until(expTime)); err != nil {
+		log.Error("failed to blacklist mfa token", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.ResetAttempts(ctx, attemptsMFA, userID); err != nil {
+		log.Warn("failed to reset second factor failures", sl.Err(err))
+	}
+
+	accessToken, refreshToken, err := s.StartSession(ctx, user, true, nil)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// ForgotPassword sends single-use password reset token to given email.
+// Unknown emails are silently ignored, so response doesn't reveal registered users
+func (s *Service) ForgotPassword(ctx context.Context, email string) error {
+	const op = "services.auth.ForgotPassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("password reset requested for unknown email", slog.String("email", email))
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Password is added from profile, reset has nothing to replace
+	if user.PassHash == nil {
+		log.Info("password reset requested for user without password", slog.String("id", user.ID))
+		return nil
+	}
+
+	token := random.Token()
+
+	if err := s.cache.SetActionToken(ctx, actionResetPassword, token, user.ID, s.mailer.ResetTTL()); err != nil {
+		log.Error("failed to put reset token to cache", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.mailer.SendResetToken(email, token); err != nil {
+		log.Error("failed to send reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets new password for user bound to reset token.
+// Credentials version is incremented, so all previously issued tokens become invalid
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	const op = "services.auth.ResetPassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	// Token is used up only after password passes policy and is hashed, so user can try again
+	userID, err := s.cache.GetActionToken(ctx, actionResetPassword, token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("reset token not found")
+			return fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+		}
+
+		log.Error("failed to get reset token from cache", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.usrRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.passwords.Check(ctx, password, user.Email, user.Name, user.Surname); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, services.HashingErr(err))
+	}
+
+	if _, err := s.cache.PopActionToken(ctx, actionResetPassword, token); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("reset token used concurrently")
+			return fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+		}
+
+		log.Error("failed to remove reset token from cache", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.usrRepo.UpdatePassword(ctx, userID, passHash); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.RemoveUserVersion(ctx, userID); err != nil {
+		log.Warn("failed to remove cached user version", sl.Err(err))
+	}
+
+	if err := services.DropSessions(ctx, s.sessRepo, s.cache, userID); err != nil {
+		log.Warn("failed to drop sessions", sl.Err(err))
+	}
+
+	log.Info("password reset", slog.String("id", userID))
+
+	return nil
+}
+
+// BeginPasskeyLogin starts WebAuthn login ceremony with discoverable credentials, so user is not asked for email.
+// Returned options are passed to navigator.credentials.get() on the client
+func (s *Service) BeginPasskeyLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	const op = "services.auth.BeginPasskeyLogin"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		log.Error("failed to begin login", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetWebAuthnSession(ctx, session.Challenge, data, time.Until(session.Expires)); err != nil {
+		log.Error("failed to save webauthn session", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return assertion, nil
+}
+
+// FinishPasskeyLogin verifies response of authenticator to login challenge and starts session.
+// Passkey verified by user (biometrics, PIN) satisfies the second factor, otherwise
+// MFARequiredError is returned for users with second factor enabled, like on SignIn
+func (s *Service) FinishPasskeyLogin(ctx context.Context, response []byte) (string, string, error) {
+	const op = "services.auth.FinishPasskeyLogin"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	parsed, err := passkey.ParseAssertion(response)
+	if err != nil {
+		log.Warn("failed to parse assertion", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	data, err := s.cache.PopWebAuthnSession(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("webauthn session not found")
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// User is resolved by user handle stored in passkey during registration
+	var user *models.User
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		u, err := s.usrRepo.GetByID(ctx, string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		passkeys, err := s.pkRepo.ListByUser(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		user = u
+
+		return passkey.NewUser(u, passkeys), nil
+	}
+
+	cred, err := s.webAuthn.ValidateDiscoverableLogin(handler, session, parsed)
+	if err != nil {
+		log.Warn("failed to verify assertion", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	// Counter going backwards means the credential private key may have been cloned
+	if cred.Authenticator.CloneWarning {
+		log.Warn("possibly cloned authenticator", slog.String("id", user.ID))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	if err := s.pkRepo.UpdateUsage(ctx, cred.ID, cred.Authenticator.SignCount, cred.Flags.BackupState); err != nil {
+		log.Error("failed to update passkey usage", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Passkey isn't guessed, but account locked under attack accepts no sign in until it is unlocked
+	if err := s.CheckAccountLock(ctx, user.ID); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Without user verification passkey proves possession only and is treated like password
+	if !cred.Flags.UserVerified {
+		accessToken, refreshToken, err := s.completeSignIn(ctx, user)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return accessToken, refreshToken, nil
+	}
+
+	accessToken, refreshToken, err := s.StartSession(ctx, user, true, nil)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// checkIPAttempts rejects client which failed to sign in too many times, whatever account it tries
+func (s *Service) checkIPAttempts(ctx context.Context, ip string) error {
+	if ip == "" || s.lockCfg.IPMaxAttempts <= 0 {
+		return nil
+	}
+
+	attempts, ttl, err := s.cache.GetAttempts(ctx, attemptsSignInIP, ip)
+	if err != nil {
+		return err
+	}
+
+	if attempts >= int64(s.lockCfg.IPMaxAttempts) {
+		http_lib.GetCtxLogger(ctx).Warn("too many failed sign ins from ip", slog.String("ip", ip))
+		return &services.RateLimitedError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+// registerSignInFailure counts failed sign in of client and user, if it is known. User is locked
+// after too many failures. Returns LockedError if this failure locks the user
+func (s *Service) registerSignInFailure(ctx context.Context, user *models.User, ip string) error {
+	if ip != "" {
+		if _, err := s.cache.IncrAttempts(ctx, attemptsSignInIP, ip, s.lockCfg.Window); err != nil {
+			return err
+		}
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	lock, err := s.countAccountFailure(ctx, user.ID)
+	if err != nil || !lock {
+		return err
+	}
+
+	return s.lockAccount(ctx, user)
+}
+
+// RegisterPasswordFailure counts wrong current password entered by signed in user like failed sign in,
+// so stolen session can't be used to guess the password. Returns LockedError if this failure locks the user
+func (s *Service) RegisterPasswordFailure(ctx context.Context, user *models.User) error {
+	return s.registerSignInFailure(ctx, user, "")
+}
+
+// CheckAccountLock returns LockedError if account with id is locked after too many failures
+func (s *Service) CheckAccountLock(ctx context.Context, id string) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	lock, err := s.cache.GetAccountLock(ctx, id)
+	if err != nil {
+		log.Error("failed to get account lock", sl.Err(err))
+		return err
+	}
+
+	if lock > 0 {
+		log.Warn("account is locked", slog.String("id", id))
+		return &services.LockedError{RetryAfter: lock}
+	}
+
+	return nil
+}
+
+// countAccountFailure counts failed sign in to account with id and reports whether it must be locked
+func (s *Service) countAccountFailure(ctx context.Context, id string) (bool, error) {
+	log := http_lib.GetCtxLogger(ctx)
+
+	if s.lockCfg.MaxAttempts <= 0 {
+		return false, nil
+	}
+
+	attempts, err := s.cache.IncrAttempts(ctx, attemptsSignIn, id, s.lockCfg.Window)
+	if err != nil {
+		return false, err
+	}
+
+	if attempts < int64(s.lockCfg.MaxAttempts) {
+		return false, nil
+	}
+
+	if err := s.cache.ResetAttempts(ctx, attemptsSignIn, id); err != nil {
+		log.Warn("failed to reset failed sign ins", sl.Err(err))
+	}
+
+	return true, nil
+}
+
+// lockDuration counts locks of account with id, every next lock within a day is twice longer
+func (s *Service) lockDuration(ctx context.Context, id string) (time.Duration, error) {
+	lockouts, err := s.cache.IncrAttempts(ctx, attemptsLockouts, id, lockoutsWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := s.lockCfg.Duration
+	for i := int64(1); i < lockouts && duration < s.lockCfg.MaxDuration; i++ {
+		duration *= 2
+	}
+
+	return min(duration, s.lockCfg.MaxDuration), nil
+}
+
+// lockAccount locks user for lockDuration. Owner gets unlock token by email. Returns LockedError
+func (s *Service) lockAccount(ctx context.Context, user *models.User) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	duration, err := s.lockDuration(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.cache.LockAccount(ctx, user.ID, duration); err != nil {
+		return err
+	}
+
+	log.Warn("account locked", slog.String("id", user.ID), slog.Duration("duration", duration))
+
+	// Owner learns about the attack and can unlock without waiting
+	token := random.Token()
+	if err := s.cache.SetActionToken(ctx, actionUnlock, token, user.ID, s.lockCfg.UnlockTTL); err != nil {
+		log.Error("failed to put unlock token to cache", sl.Err(err))
+	} else if err := s.mailer.SendUnlockToken(user.Email, token); err != nil {
+		log.Error("failed to send unlock token", sl.Err(err))
+	}
+
+	return &services.LockedError{RetryAfter: duration}
+}
+
+// Unlock removes lock of account the unlock token was emailed for
+func (s *Service) Unlock(ctx context.Context, token string) error {
+	const op = "services.auth.Unlock"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	userID, err := s.cache.PopActionToken(ctx, actionUnlock, token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("unlock token not found")
+			return fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+		}
+
+		log.Error("failed to get unlock token from cache", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.unlock(ctx, userID); err != nil {
+		log.Error("failed to unlock account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account unlocked by owner", slog.String("id", userID))
+
+	return nil
+}
+
+// ListLockedAccounts returns accounts locked after too many wrong passwords
+func (s *Service) ListLockedAccounts(ctx context.Context) ([]models.Lockout, error) {
+	const op = "services.auth.ListLockedAccounts"
+
+	locks, err := s.cache.ListLockedAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	lockouts := make([]models.Lockout, 0, len(locks))
+
+	for userID, ttl := range locks {
+		user, err := s.usrRepo.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				continue
+			}
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		lockouts = append(lockouts, models.Lockout{
+			UserID:      userID,
+			Email:       user.Email,
+			LockedUntil: now.Add(ttl).Truncate(time.Second),
+		})
+	}
+
+	slices.SortFunc(lockouts, func(a, b models.Lockout) int {
+		return a.LockedUntil.Compare(b.LockedUntil)
+	})
+
+	return lockouts, nil
+}
+
+// UnlockAccount removes lock of account by admin
+func (s *Service) UnlockAccount(ctx context.Context, userID string) error {
+	const op = "services.auth.UnlockAccount"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	lock, err := s.cache.GetAccountLock(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if lock == 0 {
+		return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	if err := s.unlock(ctx, userID); err != nil {
+		log.Error("failed to unlock account", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account unlocked by admin", slog.String("id", userID))
+
+	return nil
+}
+
+// unlock removes account lock and forgets previous failures, so next lock is the shortest again
+func (s *Service) unlock(ctx context.Context, userID string) error {
+	if err := s.cache.UnlockAccount(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.cache.ResetAttempts(ctx, attemptsSignIn, userID); err != nil {
+		return err
+	}
+
+	if err := s.cache.ResetAttempts(ctx, attemptsMFA, userID); err != nil {
+		return err
+	}
+
+	return s.cache.ResetAttempts(ctx, attemptsLockouts, userID)
+}
+
+// SendMagicLink emails single-use sign in link to confirmed user.
+// Unknown emails are silently ignored, so response doesn't reveal registered users
+func (s *Service) SendMagicLink(ctx context.Context, email string) error {
+	const op = "services.auth.SendMagicLink"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("magic link requested for unknown email", slog.String("email", email))
+			return nil
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Not confirmed accounts are activated with confirmation code only
+	if !user.IsActive {
+		log.Info("magic link requested for inactive user", slog.String("id", user.ID))
+		return nil
+	}
+
+	jti := uuid.NewString()
+	ttl := s.mailer.MagicLinkTTL()
+
+	token, err := jwt_lib.NewMagicLinkToken(user.ID, jti, time.Now().Add(ttl), s.keys.Active())
+	if err != nil {
+		log.Error("failed to generate magic link token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetActionToken(ctx, actionMagicLink, jti, user.ID, ttl); err != nil {
+		log.Error("failed to put magic link to cache", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.mailer.SendMagicLink(email, token); err != nil {
+		log.Error("failed to send magic link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLink signs in user the link was sent to. The link works once, second factor
+// is required like on SignIn
+func (s *Service) ConsumeMagicLink(ctx context.Context, token string) (string, string, error) {
+	const op = "services.auth.ConsumeMagicLink"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	claims, err := jwt_lib.FromString(token, s.keys)
+	if err != nil {
+		if errors.Is(err, jwt_lib.ErrExpired) {
+			log.Warn("token expired", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenExpired)
+		}
+
+		log.Warn("failed to extract token claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	tknType, err := jwt_lib.GetClaim(claims, "type")
+	if err != nil || tknType != "magic_link" {
+		log.Warn("unexpected token type: expected 'magic_link'")
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrUnexpectedTokenType)
+	}
+
+	userID, err := jwt_lib.GetClaim(claims, "sub")
+	if err != nil {
+		log.Error("failed to get user ID from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	jti, err := jwt_lib.GetClaim(claims, "jti")
+	if err != nil {
+		log.Error("failed to get jti from claims", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	ownerID, err := s.cache.PopActionToken(ctx, actionMagicLink, jti)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("magic link already used", slog.String("id", userID))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+		}
+
+		log.Error("failed to get magic link from cache", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ownerID != userID {
+		log.Warn("magic link issued for another user", slog.String("id", userID))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	user, err := s.usrRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("user not found", slog.String("id", userID))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Link is spent anyway, owner of locked account has got the unlock link by email
+	if err := s.CheckAccountLock(ctx, user.ID); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, refreshToken, err := s.completeSignIn(ctx, user)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user signed in with magic link", slog.String("id", userID))
+
+	return accessToken, refreshToken, nil
+}
+
+// BeginFederatedSignIn returns URL of external provider the user signs in at
+func (s *Service) BeginFederatedSignIn(ctx context.Context, provider string) (string, error) {
+	const op = "services.auth.BeginFederatedSignIn"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		log.Info("unknown provider", slog.String("provider", provider))
+		return "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	state := random.Token()
+	fs := oidc.State{
+		Provider: provider,
+		Nonce:    random.Token(),
+		Verifier: random.Token(),
+	}
+
+	url, err := p.AuthCodeURL(ctx, state, fs.Nonce, fs.Verifier)
+	if err != nil {
+		log.Error("failed to build authorization url", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(fs)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetFederationState(ctx, state, data, s.fedCfg.StateTTL); err != nil {
+		log.Error("failed to save federation state", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+// FinishFederatedSignIn redeems authorization code of external provider and signs in the user owning
+// the identity. Unknown identity is linked to the user with the same email, if the provider verified it,
+// or a new user is created. Second factor is required like on SignIn
+func (s *Service) FinishFederatedSignIn(ctx context.Context, provider, code, state string) (string, string, error) {
+	const op = "services.auth.FinishFederatedSignIn"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		log.Info("unknown provider", slog.String("provider", provider))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	data, err := s.cache.PopFederationState(ctx, state)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("federation state not found", slog.String("provider", provider))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var fs oidc.State
+	if err := json.Unmarshal(data, &fs); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if fs.Provider != provider || fs.UserID != "" {
+		log.Warn("state was issued for another provider or linking", slog.String("provider", provider))
+		return "", "", fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+	}
+
+	identity, err := p.Exchange(ctx, code, fs.Verifier, fs.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrRejected) {
+			log.Warn("provider rejected sign in", slog.String("provider", provider), sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+		}
+
+		log.Error("failed to exchange code", slog.String("provider", provider), sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.federatedUser(ctx, identity)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Locked account can't be entered through a linked identity either
+	if err := s.CheckAccountLock(ctx, user.ID); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, refreshToken, err := s.completeSignIn(ctx, user)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// federatedUser returns user owning external identity, linking or creating one if needed
+func (s *Service) federatedUser(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("provider", identity.Provider))
+
+	user, err := s.usrRepo.GetByIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+
+	if !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	// Unverified email could be anyone's, so it can't be used to find or create an account
+	if identity.Email == "" || !identity.EmailVerified {
+		log.Warn("provider didn't verify email")
+		return nil, services.ErrFederationFailed
+	}
+
+	fi := &models.FederatedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	user, err = s.usrRepo.GetByEmail(ctx, identity.Email)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	if user != nil {
+		// Inactive account may have been registered by someone else, who doesn't own the email
+		if !user.IsActive {
+			log.Warn("email belongs to not confirmed account", slog.String("id", user.ID))
+			return nil, services.ErrExists
+		}
+
+		fi.UserID = user.ID
+		if err := s.usrRepo.AddIdentity(ctx, fi); err != nil {
+			log.Error("failed to link identity", sl.Err(err))
+			return nil, err
+		}
+
+		log.Info("identity linked by verified email", slog.String("id", user.ID))
+
+		return user, nil
+	}
+
+	user = &models.User{
+		Name:    identity.GivenName,
+		Surname: identity.FamilyName,
+		Email:   identity.Email,
+	}
+
+	if err := s.usrRepo.CreateFederatedUser(ctx, user, fi); err != nil {
+		log.Error("failed to create user", sl.Err(err))
+		return nil, err
+	}
+
+	log.Info("user signed up with external provider", slog.String("id", user.ID))
+
+	return user, nil
+}
+
+// completeSignIn starts session for user, who passed the first factor.
+// Users with second factor enabled get short-lived mfa_pending token instead
+func (s *Service) completeSignIn(ctx context.Context, user *models.User) (string, string, error) {
+	const op = "services.auth.completeSignIn"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	otp, err := s.mfaRepo.GetTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		log.Error("failed to get totp", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if otp != nil && otp.IsEnabled {
+		mfaToken, err := jwt_lib.NewMFAToken(
+			user.ID,
+			uuid.NewString(),
+			time.Now().Add(s.mfaCfg.TokenTTL),
+			s.keys.Active(),
+		)
+		if err != nil {
+			log.Error("failed to generate mfa token", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("second factor required", slog.String("id", user.ID))
+		return "", "", fmt.Errorf("%s: %w", op, &services.MFARequiredError{Token: mfaToken})
+	}
+
+	accessToken, refreshToken, err := s.StartSession(ctx, user, false, nil)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// StartSession issues tokens pair opening a new refresh token family.
+// Family ID is used as session ID. Grant is set for tokens issued to OAuth clients
+func (s *Service) StartSession(ctx context.Context, user *models.User, mfa bool, grant *jwt_lib.Grant) (string, string, error) {
+	const op = "services.auth.StartSession"
+
+	familyID := uuid.NewString()
+	jti := uuid.NewString()
+
+	accessToken, refreshToken, err := s.issueTokens(user, jti, familyID, mfa, grant)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetRefreshFamily(ctx, familyID, jti, s.tknsCfg.RefreshTTL); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	client := http_lib.GetCtxClient(ctx)
+	if err := s.sessRepo.Create(ctx, &models.Session{
+		ID:        familyID,
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(s.tknsCfg.RefreshTTL),
+	}); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// useTOTPCode validates TOTP code and marks it as used.
+// Each code can be used only once within its validity window
+func (s *Service) useTOTPCode(ctx context.Context, userID, secret, code string) (bool, error) {
+	const op = "services.auth.useTOTPCode"
+
+	step, ok := totp.Validate(code, secret, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	ok, err := s.cache.MarkTOTPUsed(ctx, userID, step, totp.Validity)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+// useRecoveryCode looks for matching unused recovery code and redeems it
+func (s *Service) useRecoveryCode(ctx context.Context, userID, code string) (bool, error) {
+	const op = "services.auth.useRecoveryCode"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	codes, err := s.mfaRepo.ListUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	for _, c := range codes {
+		// Codes are checked on hashing pool, so guessing them can't take every CPU either
+		if _, err := s.hasher.Verify(c.CodeHash, code); err != nil {
+			if errors.Is(err, password_lib.ErrMismatch) {
+				continue
+			}
+
+			return false, fmt.Errorf("%s: %w", op, services.HashingErr(err))
+		}
+
+		if err := s.mfaRepo.UseRecoveryCode(ctx, c.ID); err != nil {
+			// Code has been redeemed concurrently
+			if errors.Is(err, repositories.ErrNotFound) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("recovery code used", slog.String("id", userID), slog.Int("remaining", len(codes)-1))
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// registerMFAFailure counts wrong second factor codes of user. The counter outlives mfa_pending tokens,
+// so signing in with the password again gives no extra guesses. Once the limit is reached
+// the token is revoked and the account is locked. Returns LockedError then
+func (s *Service) registerMFAFailure(ctx context.Context, user *models.User, mfaToken string, ttl time.Duration) error {
+	const op = "services.auth.registerMFAFailure"
+
+	log := http_lib.GetCtxLogger(ctx)
+
+	if s.mfaCfg.MaxAttempts <= 0 {
+		return nil
+	}
+
+	attempts, err := s.cache.IncrAttempts(ctx, attemptsMFA, user.ID, s.lockCfg.Window)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if attempts < int64(s.mfaCfg.MaxAttempts) {
+		return nil
+	}
+
+	if err := s.cache.AddToBlacklist(ctx, mfaToken, ttl); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.ResetAttempts(ctx, attemptsMFA, user.ID); err != nil {
+		log.Warn("failed to reset second factor failures", sl.Err(err))
+	}
+
+	return fmt.Errorf("%s: %w", op, s.lockAccount(ctx, user))
+}
+
+// issueTokens generates access & refresh tokens pair, refresh token gets given jti within the family
+func (s *Service) issueTokens(user *models.User, jti, familyID string, mfa bool, grant *jwt_lib.Grant) (string, string, error) {
+	const op = "services.auth.issueTokens"
+
+	accessToken, err := jwt_lib.NewAccessToken(
+		user.ID,
+		user.Role,
+		user.Version,
+		familyID,
+		mfa,
+		grant,
+		time.Now().Add(s.tknsCfg.AccessTTL),
+		s.keys.Active(),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	refreshToken, err := jwt_lib.NewRefreshToken(
+		user.ID,
+		user.Version,
+		jti,
+		familyID,
+		mfa,
+		grant,
+		time.Now().Add(s.tknsCfg.RefreshTTL),
+		s.keys.Active(),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return accessToken, refreshToken, nil
+}
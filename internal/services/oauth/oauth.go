@@ -0,0 +1,843 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"e-commerce-users/internal/config"
+	http_lib "e-commerce-users/internal/lib/http"
+	jwt_lib "e-commerce-users/internal/lib/jwt"
+	"e-commerce-users/internal/lib/random"
+	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/internal/services"
+	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+type UserRepo interface {
+	GetByID(ctx context.Context, id string) (*models.User, error)
+}
+
+type ClientRepo interface {
+	Create(ctx context.Context, client *models.OAuthClient) error
+	GetByID(ctx context.Context, id string) (*models.OAuthClient, error)
+	List(ctx context.Context) ([]models.OAuthClient, error)
+	UpdateSecret(ctx context.Context, id string, secretHash []byte) error
+	Disable(ctx context.Context, id string) error
+}
+
+type Cache interface {
+	IsBlacklisted(ctx context.Context, token string) (bool, error)
+	GetRefreshFamily(ctx context.Context, familyID string) (string, error)
+	SetAuthCode(ctx context.Context, code string, data []byte, ttl time.Duration) error
+	PopAuthCode(ctx context.Context, code string) ([]byte, error)
+}
+
+// SessionIssuer opens and refreshes sessions the same way sign in does. Implemented by auth service
+type SessionIssuer interface {
+	StartSession(ctx context.Context, user *models.User, mfa bool, grant *jwt_lib.Grant) (string, string, error)
+	RefreshGrant(ctx context.Context, refreshToken, clientID string) (string, string, error)
+}
+
+// Token kinds told apart by introspection, named after RFC 7009 token type hints
+const (
+	tokenTypeAccess  = "access_token"
+	tokenTypeRefresh = "refresh_token"
+)
+
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+	// scopeIntrospect is client scope allowing to call introspection endpoint
+	scopeIntrospect = "introspect"
+
+	codeChallengeS256 = "S256"
+	responseTypeCode  = "code"
+	tokenTypeBearer   = "Bearer"
+)
+
+// SupportedScopes are scopes clients may request
+var SupportedScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+type Service struct {
+	usrRepo  UserRepo
+	clntRepo ClientRepo
+	cache    Cache
+	sessions SessionIssuer
+	keys     *jwt_lib.KeySet
+	tknsCfg  *config.Tokens
+	oauthCfg *config.OAuth
+}
+
+type Config struct {
+	Repo          UserRepo
+	ClientRepo    ClientRepo
+	Cache         Cache
+	SessionIssuer SessionIssuer
+	SigningKeys   *jwt_lib.KeySet
+	TknsCfg       *config.Tokens
+	OAuthCfg      *config.OAuth
+}
+
+func New(cfg *Config) *Service {
+	return &Service{
+		usrRepo:  cfg.Repo,
+		clntRepo: cfg.ClientRepo,
+		cache:    cfg.Cache,
+		sessions: cfg.SessionIssuer,
+		keys:     cfg.SigningKeys,
+		tknsCfg:  cfg.TknsCfg,
+		oauthCfg: cfg.OAuthCfg,
+	}
+}
+
+// AuthenticateClient checks credentials of resource server calling introspection endpoint.
+// It must be a registered confidential client granted introspect scope
+func (s *Service) AuthenticateClient(ctx context.Context, clientID, clientSecret string) error {
+	const op = "services.oauth.AuthenticateClient"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	client, err := s.authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if client.IsPublic() || !slices.Contains(client.Scopes, scopeIntrospect) {
+		log.Warn("client is not allowed to introspect tokens", slog.String("client_id", clientID))
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidClient)
+	}
+
+	return nil
+}
+
+// Introspect reports whether access or refresh token is live: its signature and expiration are valid,
+// it is not blacklisted, its version matches the user version and the user is active.
+// Dead tokens are reported as inactive, error is returned only if the state can't be determined
+func (s *Service) Introspect(ctx context.Context, token string) (*models.Introspection, error) {
+	const op = "services.oauth.Introspect"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	claims, _, _, err := s.liveToken(ctx, log, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims == nil {
+		return &models.Introspection{Active: false}, nil
+	}
+
+	exp, _ := claims["exp"].(float64)
+	scope, _ := claims["scope"].(string)
+	userID, _ := claims["sub"].(string)
+	clientID, _ := claims["client_id"].(string)
+
+	return &models.Introspection{
+		Active:    true,
+		Subject:   userID,
+		ClientID:  clientID,
+		ExpiresAt: int64(exp),
+		Scope:     scope,
+		TokenType: tokenTypeBearer,
+	}, nil
+}
+
+// ValidateAuthorization checks authorization request before the user is asked to sign in.
+// ErrInvalidClient and ErrInvalidRedirectURI mean the error must not be sent to redirect URI
+func (s *Service) ValidateAuthorization(ctx context.Context, req *models.AuthorizationRequest) error {
+	const op = "services.oauth.ValidateAuthorization"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	client, err := s.clntRepo.GetByID(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("unknown client", slog.String("client_id", req.ClientID))
+			return fmt.Errorf("%s: %w", op, services.ErrInvalidClient)
+		}
+
+		log.Error("failed to get client", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !client.IsActive {
+		log.Info("client is disabled", slog.String("client_id", req.ClientID))
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidClient)
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		log.Info("redirect uri is not registered", slog.String("client_id", client.ID))
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidRedirectURI)
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return fmt.Errorf("%s: %w", op, services.ErrUnsupportedResponseType)
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, scopeOpenID) {
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidScope)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(SupportedScopes, scope) {
+			return fmt.Errorf("%s: %w", op, services.ErrInvalidScope)
+		}
+	}
+
+	// PKCE is required for every client, including confidential ones
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != codeChallengeS256 {
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidRequest)
+	}
+
+	return nil
+}
+
+// Authorize issues authorization code for the validated request on behalf of the user signed in
+// with the given first-party access token. ErrLoginRequired is returned if the token is not live
+func (s *Service) Authorize(ctx context.Context, req *models.AuthorizationRequest, accessToken string) (string, error) {
+	const op = "services.oauth.Authorize"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if accessToken == "" {
+		return "", fmt.Errorf("%s: %w", op, services.ErrLoginRequired)
+	}
+
+	claims, user, tokenType, err := s.liveToken(ctx, log, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Tokens issued to other clients can't be used to sign in to this one
+	if claims == nil || tokenType != tokenTypeAccess || user == nil || jwt_lib.GrantFromClaims(claims) != nil {
+		log.Info("sign in required")
+		return "", fmt.Errorf("%s: %w", op, services.ErrLoginRequired)
+	}
+
+	approved := *req
+	approved.UserID = user.ID
+	approved.MFA, _ = jwt_lib.GetBoolClaim(claims, "mfa")
+
+	data, err := json.Marshal(&approved)
+	if err != nil {
+		log.Error("failed to marshal authorization request", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	code := random.Token()
+
+	if err := s.cache.SetAuthCode(ctx, code, data, s.oauthCfg.CodeTTL); err != nil {
+		log.Error("failed to save authorization code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code issued", slog.String("client_id", req.ClientID), slog.String("id", user.ID))
+
+	return code, nil
+}
+
+// ExchangeCode redeems authorization code for access, refresh and ID tokens.
+// Code is removed on the first attempt, so a failed exchange can't be retried
+func (s *Service) ExchangeCode(
+	ctx context.Context,
+	clientID string,
+	clientSecret string,
+	code string,
+	redirectURI string,
+	codeVerifier string,
+) (*models.TokenResponse, error) {
+	const op = "services.oauth.ExchangeCode"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if _, err := s.authenticateOAuthClient(ctx, clientID, clientSecret); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if code == "" {
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidRequest)
+	}
+
+	data, err := s.cache.PopAuthCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("authorization code not found or already used", slog.String("client_id", clientID))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+		}
+
+		log.Error("failed to get authorization code", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var req models.AuthorizationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Error("failed to unmarshal authorization request", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if req.ClientID != clientID || req.RedirectURI != redirectURI {
+		log.Warn("authorization code was issued to another client or redirect uri", slog.String("client_id", clientID))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+	}
+
+	if !verifyCodeChallenge(req.CodeChallenge, codeVerifier) {
+		log.Warn("code verifier mismatch", slog.String("client_id", clientID))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+	}
+
+	user, err := s.usrRepo.GetByID(ctx, req.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("user not found", slog.String("id", req.UserID))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+		}
+
+		log.Error("failed to get user by id", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.IsActive {
+		log.Info("user is not active", slog.String("id", user.ID))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+	}
+
+	grant := &jwt_lib.Grant{
+		ClientID: clientID,
+		Scope:    req.Scope,
+	}
+
+	accessToken, refreshToken, err := s.sessions.StartSession(ctx, user, req.MFA, grant)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	idToken, err := jwt_lib.NewIDToken(
+		s.oauthCfg.Issuer,
+		user.ID,
+		clientID,
+		req.Nonce,
+		profileClaims(user, strings.Fields(req.Scope)),
+		time.Now().Add(s.tknsCfg.AccessTTL),
+		s.keys.Active(),
+	)
+	if err != nil {
+		log.Error("failed to issue id token", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code exchanged", slog.String("client_id", clientID), slog.String("id", user.ID))
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    tokenTypeBearer,
+		ExpiresIn:    int64(s.tknsCfg.AccessTTL.Seconds()),
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		Scope:        req.Scope,
+	}, nil
+}
+
+// RefreshToken rotates refresh token issued to the client by ExchangeCode
+func (s *Service) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*models.TokenResponse, error) {
+	const op = "services.oauth.RefreshToken"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if _, err := s.authenticateOAuthClient(ctx, clientID, clientSecret); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := jwt_lib.FromString(refreshToken, s.keys)
+	if err != nil {
+		log.Info("refresh token is not valid", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+	}
+
+	grant := jwt_lib.GrantFromClaims(claims)
+	if grant == nil || grant.ClientID != clientID {
+		log.Warn("refresh token was issued to another client", slog.String("client_id", clientID))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+	}
+
+	accessToken, newRefreshToken, err := s.sessions.RefreshGrant(ctx, refreshToken, clientID)
+	if err != nil {
+		if errors.Is(err, services.ErrTokenBlacklisted) ||
+			errors.Is(err, services.ErrTokenExpired) ||
+			errors.Is(err, services.ErrTokenInvalid) ||
+			errors.Is(err, services.ErrTokenRevoked) ||
+			errors.Is(err, services.ErrTokenReused) ||
+			errors.Is(err, services.ErrUnexpectedTokenType) {
+			return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidGrant)
+		}
+
+		log.Error("failed to refresh tokens", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    tokenTypeBearer,
+		ExpiresIn:    int64(s.tknsCfg.AccessTTL.Seconds()),
+		RefreshToken: newRefreshToken,
+		Scope:        grant.Scope,
+	}, nil
+}
+
+// ClientCredentials issues service token to confidential client calling other services on its own behalf.
+// Requested scope must be within client scopes, all of them are granted if none is requested
+func (s *Service) ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*models.TokenResponse, error) {
+	const op = "services.oauth.ClientCredentials"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	client, err := s.authenticateOAuthClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if client.IsPublic() {
+		log.Warn("public client requested service token", slog.String("client_id", clientID))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrUnauthorizedClient)
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+
+	for _, sc := range scopes {
+		if !slices.Contains(client.Scopes, sc) {
+			log.Warn("scope is not allowed for client", slog.String("client_id", clientID), slog.String("scope", sc))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidScope)
+		}
+	}
+
+	granted := strings.Join(scopes, " ")
+
+	token, err := jwt_lib.NewServiceToken(clientID, granted, time.Now().Add(s.tknsCfg.AccessTTL), s.keys.Active())
+	if err != nil {
+		log.Error("failed to issue service token", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("service token issued", slog.String("client_id", clientID))
+
+	return &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(s.tknsCfg.AccessTTL.Seconds()),
+		Scope:       granted,
+	}, nil
+}
+
+// CreateClient registers new client. Secret is returned only once, public clients get none
+func (s *Service) CreateClient(
+	ctx context.Context,
+	name string,
+	redirectURIs []string,
+	scopes []string,
+	public bool,
+) (*models.OAuthClient, string, error) {
+	const op = "services.oauth.CreateClient"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	client := &models.OAuthClient{
+		ID:           uuid.NewString(),
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+	}
+
+	// pgx writes nil slices as NULL
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+	if client.Scopes == nil {
+		client.Scopes = []string{}
+	}
+
+	var secret string
+
+	if !public {
+		secret, client.SecretHash = newClientSecret()
+	}
+
+	if err := s.clntRepo.Create(ctx, client); err != nil {
+		if errors.Is(err, repositories.ErrExists) {
+			return nil, "", fmt.Errorf("%s: %w", op, services.ErrExists)
+		}
+
+		log.Error("failed to create client", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client created", slog.String("client_id", client.ID))
+
+	return client, secret, nil
+}
+
+func (s *Service) ListClients(ctx context.Context) ([]models.OAuthClient, error) {
+	const op = "services.oauth.ListClients"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	clients, err := s.clntRepo.List(ctx)
+	if err != nil {
+		log.Error("failed to list clients", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return clients, nil
+}
+
+// RotateClientSecret replaces secret of confidential client. Public clients have no secret to rotate
+func (s *Service) RotateClientSecret(ctx context.Context, id string) (string, error) {
+	const op = "services.oauth.RotateClientSecret"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	client, err := s.clntRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		log.Error("failed to get client", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if client.IsPublic() {
+		return "", fmt.Errorf("%s: %w", op, services.ErrInvalidRequest)
+	}
+
+	secret, hash := newClientSecret()
+
+	if err := s.clntRepo.UpdateSecret(ctx, id, hash); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		log.Error("failed to update client secret", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client secret rotated", slog.String("client_id", id))
+
+	return secret, nil
+}
+
+// DisableClient stops client from getting new tokens. Its service tokens are reported inactive
+// right away and refresh tokens can't be redeemed, user access tokens live until they expire
+func (s *Service) DisableClient(ctx context.Context, id string) error {
+	const op = "services.oauth.DisableClient"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if err := s.clntRepo.Disable(ctx, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		log.Error("failed to disable client", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client disabled", slog.String("client_id", id))
+
+	return nil
+}
+
+// UserInfo returns claims about the owner of access token, limited to the granted scope.
+// services.ErrTokenInvalid is returned for dead tokens and ErrInvalidScope if openid scope wasn't granted
+func (s *Service) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	const op = "services.oauth.UserInfo"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	claims, user, tokenType, err := s.liveToken(ctx, log, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Service tokens have no user to tell about
+	if claims == nil || tokenType != tokenTypeAccess || user == nil {
+		return nil, fmt.Errorf("%s: %w", op, services.ErrTokenInvalid)
+	}
+
+	grant := jwt_lib.GrantFromClaims(claims)
+	if grant == nil {
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidScope)
+	}
+
+	scopes := strings.Fields(grant.Scope)
+	if !slices.Contains(scopes, scopeOpenID) {
+		return nil, fmt.Errorf("%s: %w", op, services.ErrInvalidScope)
+	}
+
+	info := profileClaims(user, scopes)
+	info["sub"] = user.ID
+
+	return info, nil
+}
+
+// liveToken verifies the token and its owner. Nil claims are returned for dead token,
+// error is returned only if the state can't be determined. Service tokens have no owner,
+// so nil user is returned for them
+func (s *Service) liveToken(ctx context.Context, log *slog.Logger, token string) (jwt.MapClaims, *models.User, string, error) {
+	claims, err := jwt_lib.FromString(token, s.keys)
+	if err != nil {
+		log.Info("token is not valid", sl.Err(err))
+		return nil, nil, "", nil
+	}
+
+	var tokenType string
+
+	switch claims["type"] {
+	case "service":
+		live, err := s.isLiveServiceToken(ctx, log, token, claims)
+		if err != nil || !live {
+			return nil, nil, "", err
+		}
+
+		return claims, nil, tokenTypeAccess, nil
+	case "access", "client_access":
+		tokenType = tokenTypeAccess
+	case "refresh":
+		tokenType = tokenTypeRefresh
+	default:
+		log.Info("token type can't be introspected")
+		return nil, nil, "", nil
+	}
+
+	blacklisted, err := s.cache.IsBlacklisted(ctx, token)
+	if err != nil {
+		log.Error("failed to check if token blacklisted", sl.Err(err))
+		return nil, nil, "", err
+	}
+
+	if blacklisted {
+		log.Info("token is blacklisted")
+		return nil, nil, "", nil
+	}
+
+	userID, err := jwt_lib.GetClaim(claims, "sub")
+	if err != nil {
+		log.Warn("failed to get user ID from claims", sl.Err(err))
+		return nil, nil, "", nil
+	}
+
+	version, err := jwt_lib.GetIntClaim(claims, "version")
+	if err != nil {
+		log.Warn("failed to get version from claims", sl.Err(err))
+		return nil, nil, "", nil
+	}
+
+	user, err := s.usrRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("token owner not found", slog.String("id", userID))
+			return nil, nil, "", nil
+		}
+
+		log.Error("failed to get user by id", sl.Err(err))
+		return nil, nil, "", err
+	}
+
+	if !user.IsActive || user.Version != version {
+		log.Info("token revoked", slog.String("id", userID))
+		return nil, nil, "", nil
+	}
+
+	if tokenType == tokenTypeRefresh {
+		current, err := s.isCurrentRefreshToken(ctx, claims)
+		if err != nil {
+			log.Error("failed to get token family", sl.Err(err))
+			return nil, nil, "", err
+		}
+
+		if !current {
+			log.Info("refresh token was rotated or its family revoked", slog.String("id", userID))
+			return nil, nil, "", nil
+		}
+	}
+
+	return claims, user, tokenType, nil
+}
+
+// isLiveServiceToken reports whether service token is not blacklisted and its client is still active
+func (s *Service) isLiveServiceToken(ctx context.Context, log *slog.Logger, token string, claims jwt.MapClaims) (bool, error) {
+	blacklisted, err := s.cache.IsBlacklisted(ctx, token)
+	if err != nil {
+		log.Error("failed to check if token blacklisted", sl.Err(err))
+		return false, err
+	}
+
+	if blacklisted {
+		log.Info("token is blacklisted")
+		return false, nil
+	}
+
+	clientID, err := jwt_lib.GetClaim(claims, "client_id")
+	if err != nil {
+		log.Warn("failed to get client ID from claims", sl.Err(err))
+		return false, nil
+	}
+
+	client, err := s.clntRepo.GetByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("token client not found", slog.String("client_id", clientID))
+			return false, nil
+		}
+
+		log.Error("failed to get client", sl.Err(err))
+		return false, err
+	}
+
+	if !client.IsActive {
+		log.Info("token client is disabled", slog.String("client_id", clientID))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// authenticateOAuthClient checks credentials of registered active client. Public clients must not send a secret
+func (s *Service) authenticateOAuthClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	log := http_lib.GetCtxLogger(ctx)
+
+	client, err := s.clntRepo.GetByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Info("unknown client", slog.String("client_id", clientID))
+			return nil, services.ErrInvalidClient
+		}
+
+		log.Error("failed to get client", sl.Err(err))
+		return nil, err
+	}
+
+	if !client.IsActive {
+		log.Info("client is disabled", slog.String("client_id", clientID))
+		return nil, services.ErrInvalidClient
+	}
+
+	if client.IsPublic() {
+		if clientSecret != "" {
+			log.Warn("public client sent a secret", slog.String("client_id", clientID))
+			return nil, services.ErrInvalidClient
+		}
+
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare(client.SecretHash, clientSecretHash(clientSecret)) != 1 {
+		log.Warn("client authentication failed", slog.String("client_id", clientID))
+		return nil, services.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// newClientSecret generates client secret and its hash
+func newClientSecret() (string, []byte) {
+	secret := random.Token()
+
+	return secret, clientSecretHash(secret)
+}
+
+// clientSecretHash returns SHA-256 digest of client secret. Secrets are random tokens
+// with full entropy, so a fast hash is enough and introspection doesn't burn CPU on bcrypt
+func clientSecretHash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+
+	return sum[:]
+}
+
+// verifyCodeChallenge checks PKCE code verifier against S256 code challenge
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if challenge == "" || verifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// profileClaims maps user fields to OpenID Connect standard claims of the granted scopes
+func profileClaims(user *models.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+
+	if slices.Contains(scopes, scopeProfile) {
+		claims["name"] = strings.TrimSpace(user.Name + " " + user.Surname)
+		claims["given_name"] = user.Name
+		claims["family_name"] = user.Surname
+		if user.Birthdate != nil {
+			claims["birthdate"] = user.Birthdate.Format(time.DateOnly)
+		}
+		claims["role"] = user.Role
+	}
+
+	if slices.Contains(scopes, scopeEmail) {
+		claims["email"] = user.Email
+		// Account gets activated by confirming email
+		claims["email_verified"] = user.IsActive
+	}
+
+	return claims
+}
+
+// isCurrentRefreshToken reports whether refresh token is the latest one of its family
+func (s *Service) isCurrentRefreshToken(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	jti, err := jwt_lib.GetClaim(claims, "jti")
+	if err != nil {
+		return false, nil
+	}
+
+	familyID, err := jwt_lib.GetClaim(claims, "fam")
+	if err != nil {
+		return false, nil
+	}
+
+	current, err := s.cache.GetRefreshFamily(ctx, familyID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return current == jti, nil
+}
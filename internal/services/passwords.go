@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	http_lib "e-commerce-users/internal/lib/http"
+	password_lib "e-commerce-users/internal/lib/password"
+	"e-commerce-users/pkg/logger/sl"
+)
+
+type PasswordPolicy interface {
+	Check(password string, personal ...string) []string
+}
+
+// BreachChecker counts how many times password was seen in known data breaches
+type BreachChecker interface {
+	Count(password string) (int, error)
+}
+
+type SessionRepo interface {
+	DeleteByUser(ctx context.Context, userID string) ([]string, error)
+}
+
+type RefreshFamilies interface {
+	RemoveRefreshFamily(ctx context.Context, familyID string) error
+}
+
+// Passwords checks new passwords of users against policy and breach corpus
+type Passwords struct {
+	policy         PasswordPolicy
+	breaches       BreachChecker
+	rejectBreached bool
+}
+
+// NewPasswords returns checker refusing passwords found in breach corpus if rejectBreached is set,
+// otherwise they are only logged
+func NewPasswords(policy PasswordPolicy, breaches BreachChecker, rejectBreached bool) *Passwords {
+	return &Passwords{
+		policy:         policy,
+		breaches:       breaches,
+		rejectBreached: rejectBreached,
+	}
+}
+
+// Check returns PasswordPolicyError if password violates policy or, in reject mode, is found
+// in breach corpus. Corpus failure only gets logged, so it doesn't block password changes
+func (p *Passwords) Check(ctx context.Context, password string, personal ...string) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	rules := p.policy.Check(password, personal...)
+
+	count, err := p.breaches.Count(password)
+	switch {
+	case err != nil:
+		log.Error("failed to look password up in breach corpus", sl.Err(err))
+	case count > 0 && p.rejectBreached:
+		rules = append(rules, "not_breached")
+	case count > 0:
+		log.Warn("password found in breach corpus is accepted", slog.Int("breaches", count))
+	}
+
+	if len(rules) > 0 {
+		log.Debug("password violates policy", slog.Any("rules", rules))
+		return &PasswordPolicyError{Rules: rules}
+	}
+
+	return nil
+}
+
+// HashingErr reports saturated hashing pool as ErrOverloaded, so client is asked to retry later
+func HashingErr(err error) error {
+	if errors.Is(err, password_lib.ErrBusy) {
+		return ErrOverloaded
+	}
+
+	return err
+}
+
+// DropSessions removes all sessions of user with their refresh token families
+func DropSessions(ctx context.Context, sessions SessionRepo, families RefreshFamilies, userID string) error {
+	const op = "services.DropSessions"
+
+	ids, err := sessions.DeleteByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, id := range ids {
+		if err := families.RemoveRefreshFamily(ctx, id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
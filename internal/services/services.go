@@ -1,18 +1,88 @@
 package services
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MFARequiredError is returned on sign in when user has second factor enabled.
+// Token must be redeemed together with the second factor code
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "mfa required"
+}
+
+// LockedError is returned on sign in to account locked after too many wrong passwords
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "account locked"
+}
+
+// RateLimitedError is returned when client made too many failed attempts and must wait
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return "rate limited"
+}
+
+// QuotaExceededError is returned when client used up its quota for the period and must wait until it renews
+type QuotaExceededError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return "quota exceeded"
+}
+
+// PasswordPolicyError is returned when password violates policy. Rules are named like validation tags
+type PasswordPolicyError struct {
+	Rules []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password violates policy: " + strings.Join(e.Rules, ",")
+}
 
 var (
 	ErrNotFound           = errors.New("not found")
 	ErrExists             = errors.New("exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrCode               = errors.New("invalid code")
+	ErrCodeExhausted      = errors.New("code attempts exhausted")
 	ErrNoActionRequired   = errors.New("no action required")
+	ErrPasskeyInvalid     = errors.New("invalid passkey response")
+	ErrInvalidClient      = errors.New("invalid client")
+	ErrFederationFailed   = errors.New("federated sign in failed")
+	ErrLastLoginMethod    = errors.New("last login method")
+	ErrOverloaded         = errors.New("overloaded")
+	ErrReauthRequired     = errors.New("reauthentication required")
+)
+
+// OAuth errors named after RFC 6749 error codes
+var (
+	ErrInvalidRequest          = errors.New("invalid request")
+	ErrInvalidRedirectURI      = errors.New("invalid redirect uri")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrInvalidGrant            = errors.New("invalid grant")
+	ErrUnauthorizedClient      = errors.New("unauthorized client")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	ErrLoginRequired           = errors.New("login required")
 )
 
 var (
 	ErrTokenInvalid        = errors.New("token invalid")
 	ErrTokenBlacklisted    = errors.New("token blacklisted")
+	ErrTokenRevoked        = errors.New("token revoked")
+	ErrTokenReused         = errors.New("token reused")
 	ErrTokenExpired        = errors.New("token expired")
 	ErrUnexpectedTokenType = errors.New("unexpected token type")
 )
@@ -2,29 +2,143 @@ package users
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	http_lib "e-commerce-users/internal/lib/http"
+	"e-commerce-users/internal/lib/oidc"
+	"e-commerce-users/internal/lib/passkey"
+	password_lib "e-commerce-users/internal/lib/password"
+	"e-commerce-users/internal/lib/random"
+	"e-commerce-users/internal/lib/totp"
 	"e-commerce-users/internal/models"
+	"e-commerce-users/internal/repositories"
+	"e-commerce-users/internal/services"
 	"e-commerce-users/pkg/logger/sl"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
 )
 
 type UserRepo interface {
 	GetByID(ctx context.Context, id string) (*models.User, error)
+	UpdatePassword(ctx context.Context, id string, passHash []byte) (int, error)
+	IncrementVersion(ctx context.Context, id string) (int, error)
+	CreatePassword(ctx context.Context, id, email string, passHash []byte) error
+	DeletePassword(ctx context.Context, id string) error
+	ListIdentities(ctx context.Context, userID string) ([]models.FederatedIdentity, error)
+	AddIdentity(ctx context.Context, identity *models.FederatedIdentity) error
+	DeleteIdentity(ctx context.Context, id, userID string) error
+}
+
+type SessionRepo interface {
+	ListByUser(ctx context.Context, userID string) ([]models.Session, error)
+	Delete(ctx context.Context, id, userID string) error
+	DeleteByUser(ctx context.Context, userID string) ([]string, error)
+}
+
+type MFARepo interface {
+	SaveTOTP(ctx context.Context, userID, secret string) error
+	GetTOTP(ctx context.Context, userID string) (*models.TOTP, error)
+	EnableTOTP(ctx context.Context, userID string) error
+	DeleteTOTP(ctx context.Context, userID string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte) error
+	CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
+
+type PasskeyRepo interface {
+	Create(ctx context.Context, passkey *models.Passkey) error
+	ListByUser(ctx context.Context, userID string) ([]models.Passkey, error)
+	Delete(ctx context.Context, id []byte, userID string) error
+}
+
+type Cache interface {
+	GetUserVersion(ctx context.Context, userID string) (int, error)
+	SetUserVersion(ctx context.Context, userID string, version int, ttl time.Duration) error
+	RemoveUserVersion(ctx context.Context, userID string) error
+	RemoveRefreshFamily(ctx context.Context, familyID string) error
+	SetWebAuthnSession(ctx context.Context, challenge string, data []byte, ttl time.Duration) error
+	PopWebAuthnSession(ctx context.Context, challenge string) ([]byte, error)
+	SetFederationState(ctx context.Context, state string, data []byte, ttl time.Duration) error
+	PopFederationState(ctx context.Context, state string) ([]byte, error)
+	MarkTOTPUsed(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error)
+}
+
+// Lockout counts wrong passwords and locks accounts the same way sign in does. Implemented by auth service
+type Lockout interface {
+	CheckAccountLock(ctx context.Context, id string) error
+	RegisterPasswordFailure(ctx context.Context, user *models.User) error
+}
+
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (bool, error)
 }
 
 type Service struct {
-	usrRepo UserRepo
+	usrRepo        UserRepo
+	sessRepo       SessionRepo
+	mfaRepo        MFARepo
+	pkRepo         PasskeyRepo
+	cache          Cache
+	hasher         PasswordHasher
+	lockout        Lockout
+	passwords      *services.Passwords
+	webAuthn       *webauthn.WebAuthn
+	versionTTL     time.Duration
+	mfaIssuer      string
+	recCodes       int
+	providers      *oidc.Providers
+	stateTTL       time.Duration
+	freshSignInTTL time.Duration
 }
 
 type Config struct {
-	Repo UserRepo
+	Repo        UserRepo
+	SessionRepo SessionRepo
+	MFARepo     MFARepo
+	PasskeyRepo PasskeyRepo
+	Cache       Cache
+	Hasher      PasswordHasher
+	Lockout     Lockout
+	Policy      services.PasswordPolicy
+	Breaches    services.BreachChecker
+	WebAuthn    *webauthn.WebAuthn
+	VersionTTL  time.Duration
+	MFAIssuer   string
+	// RecoveryCodes is number of recovery codes generated along with second factor
+	RecoveryCodes int
+	// Providers are external identity providers users link to their accounts
+	Providers          *oidc.Providers
+	FederationStateTTL time.Duration
+	// RejectBreached refuses passwords found in breach corpus instead of only logging them
+	RejectBreached bool
+	// FreshSignInTTL is time after sign in, within which user without password may set one
+	FreshSignInTTL time.Duration
 }
 
 func New(cfg *Config) *Service {
 	return &Service{
-		usrRepo: cfg.Repo,
+		usrRepo:        cfg.Repo,
+		sessRepo:       cfg.SessionRepo,
+		mfaRepo:        cfg.MFARepo,
+		pkRepo:         cfg.PasskeyRepo,
+		cache:          cfg.Cache,
+		hasher:         cfg.Hasher,
+		lockout:        cfg.Lockout,
+		passwords:      services.NewPasswords(cfg.Policy, cfg.Breaches, cfg.RejectBreached),
+		webAuthn:       cfg.WebAuthn,
+		versionTTL:     cfg.VersionTTL,
+		mfaIssuer:      cfg.MFAIssuer,
+		recCodes:       cfg.RecoveryCodes,
+		providers:      cfg.Providers,
+		stateTTL:       cfg.FederationStateTTL,
+		freshSignInTTL: cfg.FreshSignInTTL,
 	}
 }
 
@@ -42,3 +156,843 @@ func (s *Service) GetProfile(ctx context.Context, id string) (*models.User, erro
 
 	return user, nil
 }
+
+// ChangePassword replaces user password after verifying the current one.
+// Credentials version is incremented, so all previously issued tokens become invalid
+func (s *Service) ChangePassword(ctx context.Context, id, currentPassword, newPassword string) error {
+	const op = "services.users.ChangePassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Error("failed to get user by id", sl.Err(err))
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassHash == nil {
+		log.Warn("user has no password", slog.String("id", id))
+		return fmt.Errorf("%s: %w", op, services.ErrInvalidCredentials)
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, currentPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.passwords.Check(ctx, newPassword, user.Email, user.Name, user.Surname); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed to hash password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, services.HashingErr(err))
+	}
+
+	if _, err := s.usrRepo.UpdatePassword(ctx, id, passHash); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.RemoveUserVersion(ctx, id); err != nil {
+		log.Warn("failed to remove cached user version", sl.Err(err))
+	}
+
+	if err := services.DropSessions(ctx, s.sessRepo, s.cache, id); err != nil {
+		log.Warn("failed to drop sessions", sl.Err(err))
+	}
+
+	log.Info("password changed", slog.String("id", id))
+
+	return nil
+}
+
+// RevokeAllSessions increments credentials version, so every token issued for user becomes invalid
+func (s *Service) RevokeAllSessions(ctx context.Context, id string) error {
+	const op = "services.users.RevokeAllSessions"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if _, err := s.usrRepo.IncrementVersion(ctx, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.RemoveUserVersion(ctx, id); err != nil {
+		log.Warn("failed to remove cached user version", sl.Err(err))
+	}
+
+	if err := services.DropSessions(ctx, s.sessRepo, s.cache, id); err != nil {
+		log.Warn("failed to drop sessions", sl.Err(err))
+	}
+
+	log.Info("all sessions revoked", slog.String("id", id))
+
+	return nil
+}
+
+// IsVersionActual checks that token version matches the actual user version.
+// User version is cached for a short time to avoid hitting storage on every request
+func (s *Service) IsVersionActual(ctx context.Context, id string, version int) (bool, error) {
+	const op = "services.users.IsVersionActual"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	actual, err := s.cache.GetUserVersion(ctx, id)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			log.Error("failed to get cached user version", sl.Err(err))
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		user, err := s.usrRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				log.Warn("token owner not found", slog.String("id", id))
+				return false, nil
+			}
+
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		actual = user.Version
+
+		if err := s.cache.SetUserVersion(ctx, id, actual, s.versionTTL); err != nil {
+			log.Warn("failed to cache user version", sl.Err(err))
+		}
+	}
+
+	if version != actual {
+		log.Warn("token version is outdated", slog.String("id", id))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// ListSessions returns active sessions of user, marking the one request is made from
+func (s *Service) ListSessions(ctx context.Context, id, currentSessionID string) ([]models.Session, error) {
+	const op = "services.users.ListSessions"
+
+	sessions, err := s.sessRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession removes user session, so its refresh token can't be used anymore
+func (s *Service) RevokeSession(ctx context.Context, id, sessionID string) error {
+	const op = "services.users.RevokeSession"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	if err := s.sessRepo.Delete(ctx, sessionID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.RemoveRefreshFamily(ctx, sessionID); err != nil {
+		log.Error("failed to revoke token family", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked", slog.String("id", id), slog.String("session_id", sessionID))
+
+	return nil
+}
+
+// EnrollTOTP generates new TOTP secret for user. Second factor stays inactive
+// until user confirms it with a code from authenticator app
+func (s *Service) EnrollTOTP(ctx context.Context, id string) (string, string, error) {
+	const op = "services.users.EnrollTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		log.Error("failed to get user by id", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate totp secret", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.mfaRepo.SaveTOTP(ctx, id, secret); err != nil {
+		if errors.Is(err, repositories.ErrExists) {
+			log.Info("totp already enabled", slog.String("id", id))
+			return "", "", fmt.Errorf("%s: %w", op, services.ErrExists)
+		}
+
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return secret, totp.URI(s.mfaIssuer, user.Email, secret), nil
+}
+
+// ActivateTOTP enables pending TOTP second factor after verifying code.
+// Returns one-time recovery codes which are shown to user only once
+func (s *Service) ActivateTOTP(ctx context.Context, id, code string) ([]string, error) {
+	const op = "services.users.ActivateTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	otp, err := s.mfaRepo.GetTOTP(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if otp.IsEnabled {
+		return nil, fmt.Errorf("%s: %w", op, services.ErrNoActionRequired)
+	}
+
+	ok, err := s.useTOTPCode(ctx, id, otp.Secret, code)
+	if err != nil {
+		log.Error("failed to mark totp code as used", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !ok {
+		log.Warn("invalid totp code", slog.String("id", id))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrCode)
+	}
+
+	// Codes are generated first, so busy hashing pool doesn't leave second factor without them
+	codes, err := s.generateRecoveryCodes(ctx, id)
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.mfaRepo.EnableTOTP(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled", slog.String("id", id))
+
+	return codes, nil
+}
+
+// DisableTOTP removes TOTP second factor along with recovery codes after verifying code
+func (s *Service) DisableTOTP(ctx context.Context, id, code string) error {
+	const op = "services.users.DisableTOTP"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	otp, err := s.mfaRepo.GetTOTP(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !otp.IsEnabled {
+		return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	ok, err := s.useTOTPCode(ctx, id, otp.Secret, code)
+	if err != nil {
+		log.Error("failed to mark totp code as used", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !ok {
+		log.Warn("invalid totp code", slog.String("id", id))
+		return fmt.Errorf("%s: %w", op, services.ErrCode)
+	}
+
+	if err := s.mfaRepo.DeleteTOTP(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp disabled", slog.String("id", id))
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of user with new ones.
+// Requires enabled second factor
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, id string) ([]string, error) {
+	const op = "services.users.RegenerateRecoveryCodes"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if err := s.ensureMFAEnabled(ctx, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := s.generateRecoveryCodes(ctx, id)
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated", slog.String("id", id))
+
+	return codes, nil
+}
+
+// CountRecoveryCodes returns number of unused recovery codes of user
+func (s *Service) CountRecoveryCodes(ctx context.Context, id string) (int, error) {
+	const op = "services.users.CountRecoveryCodes"
+
+	if err := s.ensureMFAEnabled(ctx, id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := s.mfaRepo.CountUnusedRecoveryCodes(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// ensureMFAEnabled returns services.ErrNotFound if user has no active second factor
+func (s *Service) ensureMFAEnabled(ctx context.Context, id string) error {
+	otp, err := s.mfaRepo.GetTOTP(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return services.ErrNotFound
+		}
+
+		return err
+	}
+
+	if !otp.IsEnabled {
+		return services.ErrNotFound
+	}
+
+	return nil
+}
+
+// verifyCurrentPassword checks password of signed in user. Wrong ones are counted towards account lock,
+// and locked account accepts none, so session can't be used to guess the password
+func (s *Service) verifyCurrentPassword(ctx context.Context, user *models.User, password string) error {
+	log := http_lib.GetCtxLogger(ctx)
+
+	if err := s.lockout.CheckAccountLock(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if _, err := s.hasher.Verify(user.PassHash, password); err != nil {
+		if !errors.Is(err, password_lib.ErrMismatch) {
+			log.Error("failed to compare password hash", sl.Err(err))
+			return services.HashingErr(err)
+		}
+
+		log.Warn("invalid current password", slog.String("id", user.ID))
+
+		if err := s.lockout.RegisterPasswordFailure(ctx, user); err != nil {
+			return err
+		}
+
+		return services.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// useTOTPCode validates TOTP code and marks it as used, so code accepted
+// by sign in or another settings change can't be replayed
+func (s *Service) useTOTPCode(ctx context.Context, id, secret, code string) (bool, error) {
+	const op = "services.users.useTOTPCode"
+
+	step, ok := totp.Validate(code, secret, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	ok, err := s.cache.MarkTOTPUsed(ctx, id, step, totp.Validity)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+// generateRecoveryCodes replaces recovery codes of user and returns them in plain form
+func (s *Service) generateRecoveryCodes(ctx context.Context, id string) ([]string, error) {
+	const op = "services.users.generateRecoveryCodes"
+
+	codes := make([]string, s.recCodes)
+	hashes := make([][]byte, s.recCodes)
+
+	for i := range codes {
+		codes[i] = random.RecoveryCode()
+
+		hash, err := s.hasher.Hash(codes[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, services.HashingErr(err))
+		}
+
+		hashes[i] = hash
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, id, hashes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+// BeginPasskeyRegistration starts WebAuthn registration ceremony.
+// Returned options are passed to navigator.credentials.create() on the client
+func (s *Service) BeginPasskeyRegistration(ctx context.Context, id string) (*protocol.CredentialCreation, error) {
+	const op = "services.users.BeginPasskeyRegistration"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.passkeyUser(ctx, id)
+	if err != nil {
+		log.Error("failed to get user passkeys", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(user.Exclusions()))
+	if err != nil {
+		log.Error("failed to begin registration", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetWebAuthnSession(ctx, session.Challenge, data, time.Until(session.Expires)); err != nil {
+		log.Error("failed to save webauthn session", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return creation, nil
+}
+
+// FinishPasskeyRegistration verifies response of authenticator to registration challenge and saves new passkey
+func (s *Service) FinishPasskeyRegistration(ctx context.Context, id, name string, response []byte) (*models.Passkey, error) {
+	const op = "services.users.FinishPasskeyRegistration"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	parsed, err := passkey.ParseCreation(response)
+	if err != nil {
+		log.Warn("failed to parse credential", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	data, err := s.cache.PopWebAuthnSession(ctx, parsed.Response.CollectedClientData.Challenge)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("webauthn session not found", slog.String("id", id))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if string(session.UserID) != id {
+		log.Warn("webauthn session belongs to another user", slog.String("id", id))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	user, err := s.passkeyUser(ctx, id)
+	if err != nil {
+		log.Error("failed to get user passkeys", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cred, err := s.webAuthn.CreateCredential(user, session, parsed)
+	if err != nil {
+		log.Warn("failed to verify credential", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrPasskeyInvalid)
+	}
+
+	pk := passkey.NewPasskey(id, name, cred)
+	if err := s.pkRepo.Create(ctx, pk); err != nil {
+		if errors.Is(err, repositories.ErrExists) {
+			return nil, fmt.Errorf("%s: %w", op, services.ErrExists)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered", slog.String("id", id))
+
+	return pk, nil
+}
+
+func (s *Service) ListPasskeys(ctx context.Context, id string) ([]models.Passkey, error) {
+	const op = "services.users.ListPasskeys"
+
+	passkeys, err := s.pkRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return passkeys, nil
+}
+
+func (s *Service) DeletePasskey(ctx context.Context, id string, passkeyID []byte) error {
+	const op = "services.users.DeletePasskey"
+
+	if err := s.pkRepo.Delete(ctx, passkeyID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+		if errors.Is(err, repositories.ErrLastLoginMethod) {
+			return fmt.Errorf("%s: %w", op, services.ErrLastLoginMethod)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListLoginMethods returns password, external identities and passkeys user signs in with
+func (s *Service) ListLoginMethods(ctx context.Context, id string) (*models.LoginMethods, error) {
+	const op = "services.users.ListLoginMethods"
+
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identities, err := s.usrRepo.ListIdentities(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passkeys, err := s.pkRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &models.LoginMethods{
+		Password:   user.PassHash != nil,
+		Identities: identities,
+		Passkeys:   passkeys,
+	}, nil
+}
+
+// SetPassword adds password to user signed up with external provider. Email of the user becomes login.
+// User has no password to confirm, so the session must be signed in within FreshSignInTTL.
+// Credentials version is incremented, so all previously issued tokens become invalid
+func (s *Service) SetPassword(ctx context.Context, id, sessionID, password string) error {
+	const op = "services.users.SetPassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassHash != nil {
+		log.Info("user already has password", slog.String("id", id))
+		return fmt.Errorf("%s: %w", op, services.ErrExists)
+	}
+
+	sessions, err := s.sessRepo.ListByUser(ctx, id)
+	if err != nil {
+		log.Error("failed to list sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	idx := slices.IndexFunc(sessions, func(session models.Session) bool { return session.ID == sessionID })
+	if idx < 0 || time.Since(sessions[idx].CreatedAt) > s.freshSignInTTL {
+		log.Warn("sign in is not fresh", slog.String("id", id), slog.String("session_id", sessionID))
+		return fmt.Errorf("%s: %w", op, services.ErrReauthRequired)
+	}
+
+	if err := s.passwords.Check(ctx, password, user.Email, user.Name, user.Surname); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to hash password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, services.HashingErr(err))
+	}
+
+	if err := s.usrRepo.CreatePassword(ctx, id, user.Email, passHash); err != nil {
+		if errors.Is(err, repositories.ErrExists) {
+			log.Warn("email is taken by another user", slog.String("id", id))
+			return fmt.Errorf("%s: %w", op, services.ErrExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.revokeTokens(ctx, id)
+
+	log.Info("password set", slog.String("id", id))
+
+	return nil
+}
+
+// RemovePassword removes password of user who signs in with external identity or passkey after verifying it.
+// Credentials version is incremented, so all previously issued tokens become invalid
+func (s *Service) RemovePassword(ctx context.Context, id, currentPassword string) error {
+	const op = "services.users.RemovePassword"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.PassHash == nil {
+		log.Info("user has no password", slog.String("id", id))
+		return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, currentPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.usrRepo.DeletePassword(ctx, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+		if errors.Is(err, repositories.ErrLastLoginMethod) {
+			return fmt.Errorf("%s: %w", op, services.ErrLastLoginMethod)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.revokeTokens(ctx, id)
+
+	log.Info("password removed", slog.String("id", id))
+
+	return nil
+}
+
+// BeginIdentityLink returns URL of external provider signed in user links account at
+func (s *Service) BeginIdentityLink(ctx context.Context, id, provider string) (string, error) {
+	const op = "services.users.BeginIdentityLink"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		log.Info("unknown provider", slog.String("provider", provider))
+		return "", fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	state := random.Token()
+	fs := oidc.State{
+		Provider: provider,
+		Nonce:    random.Token(),
+		Verifier: random.Token(),
+		UserID:   id,
+	}
+
+	url, err := p.AuthCodeURL(ctx, state, fs.Nonce, fs.Verifier)
+	if err != nil {
+		log.Error("failed to build authorization url", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(fs)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.cache.SetFederationState(ctx, state, data, s.stateTTL); err != nil {
+		log.Error("failed to save federation state", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+// FinishIdentityLink redeems authorization code of external provider and links the identity to user.
+// Returns ErrExists if the identity is already linked to any user
+func (s *Service) FinishIdentityLink(ctx context.Context, id, provider, code, state string) (*models.FederatedIdentity, error) {
+	const op = "services.users.FinishIdentityLink"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	p, err := s.providers.Get(provider)
+	if err != nil {
+		log.Info("unknown provider", slog.String("provider", provider))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	data, err := s.cache.PopFederationState(ctx, state)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			log.Warn("federation state not found", slog.String("provider", provider))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var fs oidc.State
+	if err := json.Unmarshal(data, &fs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// State of another user would link their identity to this account
+	if fs.Provider != provider || fs.UserID != id {
+		log.Warn("state was issued for another provider or user", slog.String("id", id))
+		return nil, fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+	}
+
+	identity, err := p.Exchange(ctx, code, fs.Verifier, fs.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrRejected) {
+			log.Warn("provider rejected sign in", slog.String("provider", provider), sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrFederationFailed)
+		}
+
+		log.Error("failed to exchange code", slog.String("provider", provider), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	fi := &models.FederatedIdentity{
+		UserID:   id,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+
+	if err := s.usrRepo.AddIdentity(ctx, fi); err != nil {
+		if errors.Is(err, repositories.ErrExists) {
+			log.Warn("identity is already linked", slog.String("id", id), slog.String("provider", provider))
+			return nil, fmt.Errorf("%s: %w", op, services.ErrExists)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("identity linked", slog.String("id", id), slog.String("provider", provider))
+
+	return fi, nil
+}
+
+// UnlinkIdentity removes external identity of user, unless it is the only way to sign in
+func (s *Service) UnlinkIdentity(ctx context.Context, id, identityID string) error {
+	const op = "services.users.UnlinkIdentity"
+
+	log := http_lib.GetCtxLogger(ctx)
+	log = log.With(slog.String("op", op))
+
+	if _, err := uuid.Parse(identityID); err != nil {
+		return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+	}
+
+	if err := s.usrRepo.DeleteIdentity(ctx, identityID, id); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return fmt.Errorf("%s: %w", op, services.ErrNotFound)
+		}
+		if errors.Is(err, repositories.ErrLastLoginMethod) {
+			return fmt.Errorf("%s: %w", op, services.ErrLastLoginMethod)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("identity unlinked", slog.String("id", id))
+
+	return nil
+}
+
+// passkeyUser loads user together with registered passkeys
+func (s *Service) passkeyUser(ctx context.Context, id string) (*passkey.User, error) {
+	user, err := s.usrRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.pkRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return passkey.NewUser(user, passkeys), nil
+}
+
+// revokeTokens increments credentials version and drops sessions after login methods changed.
+// Failures only get logged, as the change itself is already saved
+func (s *Service) revokeTokens(ctx context.Context, id string) {
+	log := http_lib.GetCtxLogger(ctx)
+
+	if _, err := s.usrRepo.IncrementVersion(ctx, id); err != nil {
+		log.Error("failed to increment version", sl.Err(err))
+	}
+
+	if err := s.cache.RemoveUserVersion(ctx, id); err != nil {
+		log.Warn("failed to remove cached user version", sl.Err(err))
+	}
+
+	if err := services.DropSessions(ctx, s.sessRepo, s.cache, id); err != nil {
+		log.Warn("failed to drop sessions", sl.Err(err))
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    user_id UUID PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    is_enabled BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS is_active;
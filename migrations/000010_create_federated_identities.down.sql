@@ -0,0 +1,24 @@
+-- Users without password or birthdate can't be kept by the old schema, refuse to roll back instead of deleting them
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM users u
+        WHERE u.birthdate IS NULL
+        OR NOT EXISTS (SELECT 1 FROM local_credentials lc WHERE lc.user_id = u.id)
+    ) THEN
+        RAISE EXCEPTION 'users without password or birthdate exist, set them before rolling back';
+    END IF;
+END
+$$;
+
+ALTER TABLE users ALTER COLUMN birthdate SET NOT NULL;
+
+ALTER TABLE local_credentials ADD COLUMN IF NOT EXISTS version INT DEFAULT 1;
+
+UPDATE local_credentials lc SET version = u.version
+FROM users u
+WHERE lc.user_id = u.id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+
+DROP TABLE IF EXISTS federated_identities;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS federated_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id
+ON federated_identities(user_id);
+
+-- Version belongs to the user, so users without password can be signed out everywhere too
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+UPDATE users u SET version = lc.version
+FROM local_credentials lc
+WHERE lc.user_id = u.id;
+
+ALTER TABLE local_credentials DROP COLUMN IF EXISTS version;
+
+-- Identity providers don't share birthdate
+ALTER TABLE users ALTER COLUMN birthdate DROP NOT NULL;
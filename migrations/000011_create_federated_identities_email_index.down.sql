@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_federated_identities_email;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_federated_identities_email
+ON federated_identities(email);